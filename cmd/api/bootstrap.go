@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/yansilvacerqueira/api-files/internal/users/entity"
+	"github.com/yansilvacerqueira/api-files/internal/users/repository"
+	"github.com/yansilvacerqueira/api-files/packages/database"
+)
+
+// bootstrapAdmin creates the first administrator from the command line, for operators who already
+// have access to the database:
+//
+//	api bootstrap-admin -name "Jane Doe" -email jane@example.com
+//
+// The password is read from ADMIN_PASSWORD so it stays out of the process list and shell history.
+// It fails once any administrator exists; further ones are promoted through the users API.
+func bootstrapAdmin(args []string) error {
+	flags := flag.NewFlagSet("bootstrap-admin", flag.ContinueOnError)
+	name := flags.String("name", "", "full name of the administrator")
+	email := flags.String("email", "", "email address of the administrator")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	user, err := entity.NewUser(*name, *email, os.Getenv("ADMIN_PASSWORD"))
+	if err != nil {
+		return err
+	}
+
+	// The operator vouches for the address
+	user.MarkEmailVerified()
+
+	db, err := database.NewConnection(5, 2*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to connect to the database: %w", err)
+	}
+	defer database.Close(db)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := repository.NewUserRepository(db).CreateFirstAdmin(ctx, user); err != nil {
+		return err
+	}
+
+	log.Printf("Administrator %d created for %s", user.ID, user.Email)
+	return nil
+}
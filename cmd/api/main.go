@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/yansilvacerqueira/api-files/internal/auth"
+	"github.com/yansilvacerqueira/api-files/internal/bucket"
+	"github.com/yansilvacerqueira/api-files/internal/files"
+	"github.com/yansilvacerqueira/api-files/internal/folders"
+	"github.com/yansilvacerqueira/api-files/internal/mail"
+	"github.com/yansilvacerqueira/api-files/internal/queue"
+	"github.com/yansilvacerqueira/api-files/internal/system"
+	"github.com/yansilvacerqueira/api-files/internal/users"
+	"github.com/yansilvacerqueira/api-files/packages/database"
+	"github.com/yansilvacerqueira/api-files/packages/env"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "bootstrap-admin" {
+		if err := bootstrapAdmin(os.Args[2:]); err != nil {
+			log.Fatalf("Failed to create the administrator: %v", err)
+		}
+		return
+	}
+
+	logger := log.Default()
+
+	// Bucket configuration: uploads go to the raw bucket, compressed copies are read from the compact one
+	bucketConfig, err := bucket.LoadEnvConfig()
+	if err != nil {
+		log.Fatalf("Failed to load the bucket configuration: %v", err)
+	}
+
+	rawBucket, err := bucketConfig.NewBucket(bucketConfig.RawBucket, bucketConfig.RawBucket)
+	if err != nil {
+		log.Fatalf("Failed to connect to the bucket: %v", err)
+	}
+
+	compactBucket, err := bucketConfig.NewBucket(bucketConfig.CompactBucket, bucketConfig.CompactBucket)
+	if err != nil {
+		log.Fatalf("Failed to connect to the bucket: %v", err)
+	}
+
+	db, err := database.NewConnection(5, 2*time.Second)
+	if err != nil {
+		log.Fatalf("Failed to connect to the database: %v", err)
+	}
+	defer database.Close(db)
+
+	// The API only publishes, so it declares the same topology as the worker but never consumes
+	queueClient, err := queue.NewQueue(queue.RabbitMQ, queue.RabbitMQConfig{
+		URL:       os.Getenv("RABBIT_URL"),
+		QueueName: os.Getenv("RABBIT_TOPIC_NAME"),
+		Timeout:   time.Second * 30,
+		OnStateChange: func(state queue.ConnectionState, err error) {
+			if err != nil {
+				log.Printf("Queue connection %s: %v", state, err)
+				return
+			}
+			log.Printf("Queue connection %s", state)
+		},
+	})
+	if err != nil {
+		log.Fatalf("Failed to connect to the queue: %v", err)
+	}
+	defer func() {
+		if err := queueClient.Close(); err != nil {
+			log.Printf("Error closing the queue connection: %v", err)
+		}
+	}()
+
+	tokens, err := auth.NewTokenSigner([]byte(os.Getenv("AUTH_SECRET")), env.Duration("AUTH_TOKEN_TTL", 24*time.Hour))
+	if err != nil {
+		log.Fatalf("Failed to configure the token signer: %v", err)
+	}
+
+	mailer, err := newMailer()
+	if err != nil {
+		log.Fatalf("Failed to configure the mail sender: %v", err)
+	}
+
+	// Route groups; every group but auth authenticates through the auth handler
+	authHandler, err := auth.NewHandler(auth.Config{
+		DB:               db,
+		Logger:           logger,
+		Tokens:           tokens,
+		Mailer:           mailer,
+		ResetPasswordURL: os.Getenv("AUTH_RESET_PASSWORD_URL"),
+		VerifyEmailURL:   os.Getenv("AUTH_VERIFY_EMAIL_URL"),
+	})
+	if err != nil {
+		log.Fatalf("Failed to configure the auth handler: %v", err)
+	}
+
+	usersHandler, err := users.NewHandler(users.Config{DB: db, Logger: logger, Auth: authHandler})
+	if err != nil {
+		log.Fatalf("Failed to configure the users handler: %v", err)
+	}
+
+	foldersHandler, err := folders.NewHandler(folders.Config{DB: db, Logger: logger, Auth: authHandler})
+	if err != nil {
+		log.Fatalf("Failed to configure the folders handler: %v", err)
+	}
+
+	filesHandler, err := files.NewHandler(files.Config{
+		DB:            db,
+		Logger:        logger,
+		Auth:          authHandler,
+		Raw:           rawBucket,
+		Compact:       compactBucket,
+		Queue:         queueClient,
+		MaxUploadSize: int64(env.Int("API_MAX_UPLOAD_SIZE", 0)),
+		StreamTimeout: env.Duration("API_STREAM_TIMEOUT", time.Minute),
+	})
+	if err != nil {
+		log.Fatalf("Failed to configure the files handler: %v", err)
+	}
+
+	systemHandler, err := system.NewHandler(system.Config{DB: db, Logger: logger, Auth: authHandler, Queue: queueClient})
+	if err != nil {
+		log.Fatalf("Failed to configure the system handler: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	authHandler.SetRoutes(mux)
+	usersHandler.SetRoutes(mux)
+	foldersHandler.SetRoutes(mux)
+	filesHandler.SetRoutes(mux)
+	systemHandler.SetRoutes(mux)
+
+	// Read and write timeouts are sized for JSON requests; uploads and downloads extend their
+	// deadlines as data flows and are only cut off once they stall for API_STREAM_TIMEOUT
+	server := &http.Server{
+		Addr:              env.String("API_ADDR", ":8080"),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       env.Duration("API_READ_TIMEOUT", 30*time.Second),
+		WriteTimeout:      env.Duration("API_WRITE_TIMEOUT", 30*time.Second),
+		IdleTimeout:       env.Duration("API_IDLE_TIMEOUT", 2*time.Minute),
+		ErrorLog:          logger,
+	}
+
+	// Stop accepting connections on SIGINT/SIGTERM and let requests in progress finish
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("API listening on %s", server.Addr)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Error serving the API: %v", err)
+		}
+		return
+
+	case <-ctx.Done():
+	}
+
+	drainTimeout := env.Duration("API_SHUTDOWN_TIMEOUT", 30*time.Second)
+	log.Printf("Shutting down, waiting up to %s for open requests", drainTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		// Requests still running are cut off when the process exits
+		log.Printf("Error draining connections: %v", err)
+		server.Close()
+	}
+}
+
+// newMailer delivers through SMTP when SMTP_HOST is set and writes messages to stdout otherwise
+func newMailer() (mail.Sender, error) {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		log.Printf("SMTP_HOST is not set, writing emails to stdout")
+		return mail.NewLogSender(os.Stdout), nil
+	}
+
+	return mail.NewSMTPSender(mail.SMTPConfig{
+		Host:     host,
+		Port:     env.Int("SMTP_PORT", 0),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+	})
+}
@@ -1,99 +1,114 @@
-package worker
+package main
 
 import (
-	"bytes"
-	"compress/gzip"
-	"fmt"
-	"io"
+	"context"
+	"errors"
 	"log"
 	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/yansilvacerqueira/api-files/internal/bucket"
+	"github.com/yansilvacerqueira/api-files/internal/codec"
+	"github.com/yansilvacerqueira/api-files/internal/files/repository"
+	jobRepository "github.com/yansilvacerqueira/api-files/internal/jobs/repository"
 	"github.com/yansilvacerqueira/api-files/internal/queue"
+	"github.com/yansilvacerqueira/api-files/internal/worker"
+	"github.com/yansilvacerqueira/api-files/packages/database"
+	"github.com/yansilvacerqueira/api-files/packages/env"
 )
 
-// TODO: improving the architecture of this code
 func main() {
-	// RabbitMQ queue configuration
-	rabbitConfig := queue.RabbitMQConfig{
-		URL:       os.Getenv("RABBIT_URL"),
-		QueueName: os.Getenv("RABBIT_TOPIC_NAME"),
-		Timeout:   time.Second * 30,
-	}
+	// Worker tuning; compression and checksums stream through fixed-size buffers, while thumbnails
+	// decode whole images and are limited separately by WORKER_THUMBNAIL_CONCURRENCY
+	concurrency := env.Int("WORKER_CONCURRENCY", runtime.NumCPU())
+	jobTimeout := env.Duration("WORKER_JOB_TIMEOUT", time.Hour)
 
-	queueClient, err := queue.NewQueue(queue.RabbitMQ, rabbitConfig)
+	// Bucket configuration: the raw bucket downloads uploads and writes into the compact bucket
+	bucketConfig, err := bucket.LoadEnvConfig()
 	if err != nil {
-		log.Fatalf("Failed to connect to the queue: %v", err)
+		log.Fatalf("Failed to load the bucket configuration: %v", err)
 	}
 
-	msgChannel := make(chan queue.QueueMessage)
-	queueClient.ReceiveMessage(msgChannel)
-
-	// AWS bucket configuration
-	bucketConfig := bucket.AWSconfig{
-		Config: aws.Config{
-			Region:      aws.String(os.Getenv("AWS_REGION")),
-			Credentials: credentials.NewStaticCredentials(os.Getenv("AWS_KEY"), os.Getenv("AWS_SECRET"), ""),
-		},
-		BucketDownload: "drive-raw",
-		BucketUpload:   "drive-compact",
+	rawBucket, err := bucketConfig.NewBucket(bucketConfig.RawBucket, bucketConfig.CompactBucket)
+	if err != nil {
+		log.Fatalf("Failed to connect to the bucket: %v", err)
 	}
 
-	awsBucket, err := bucket.NewAWSBucket(bucketConfig)
+	compactBucket, err := bucketConfig.NewBucket(bucketConfig.CompactBucket, bucketConfig.CompactBucket)
 	if err != nil {
 		log.Fatalf("Failed to connect to the bucket: %v", err)
 	}
 
-	// Processing messages from the queue
-	for message := range msgChannel {
-		sourcePath := fmt.Sprintf("%s/%s", message.Path, message.Filename)
-		destinationPath := fmt.Sprintf("%d/%s", message.ID, message.Filename)
-
-		file, err := awsBucket.Download(sourcePath, destinationPath)
-		if err != nil {
-			log.Printf("Error downloading file: %v", err)
-			continue
-		}
-
-		fileContent, err := io.ReadAll(file)
-		if err != nil {
-			log.Printf("Error reading file content: %v", err)
-			continue
-		}
+	// Database connection used to record file metadata and the job status
+	db, err := database.NewConnection(5, 2*time.Second)
+	if err != nil {
+		log.Fatalf("Failed to connect to the database: %v", err)
+	}
+	defer database.Close(db)
+
+	processor, err := worker.NewProcessor(worker.Config{
+		Raw:                  rawBucket,
+		Compact:              compactBucket,
+		Files:                repository.NewFileRepository(db),
+		Jobs:                 jobRepository.NewJobRepository(db),
+		Logger:               log.Default(),
+		DefaultCodec:         os.Getenv("WORKER_CODEC"),
+		DefaultLevel:         compressionLevel(),
+		ThumbnailSize:        env.Int("WORKER_THUMBNAIL_SIZE", 0),
+		DrainTimeout:         env.Duration("WORKER_DRAIN_TIMEOUT", 30*time.Second),
+		ThumbnailConcurrency: env.Int("WORKER_THUMBNAIL_CONCURRENCY", 0),
+	})
+	if err != nil {
+		log.Fatalf("Failed to configure the worker: %v", err)
+	}
 
-		// Compressing the file
-		var compressedBuffer bytes.Buffer
-		gzipWriter := gzip.NewWriter(&compressedBuffer)
+	// RabbitMQ queue configuration; JobTimeout caps every handler on top of its own timeout
+	rabbitConfig := queue.RabbitMQConfig{
+		URL:           os.Getenv("RABBIT_URL"),
+		QueueName:     os.Getenv("RABBIT_TOPIC_NAME"),
+		Timeout:       time.Second * 30,
+		Concurrency:   concurrency,
+		JobTimeout:    jobTimeout,
+		RetryPolicies: processor.RetryPolicies(),
+		OnStateChange: func(state queue.ConnectionState, err error) {
+			if err != nil {
+				log.Printf("Queue connection %s: %v", state, err)
+				return
+			}
+			log.Printf("Queue connection %s", state)
+		},
+	}
 
-		if _, err = gzipWriter.Write(fileContent); err != nil {
-			log.Printf("Error compressing file: %v", err)
-			continue
-		}
+	queueClient, err := queue.NewQueue(queue.RabbitMQ, rabbitConfig)
+	if err != nil {
+		log.Fatalf("Failed to connect to the queue: %v", err)
+	}
 
-		if err = gzipWriter.Close(); err != nil {
-			log.Printf("Error closing gzip writer: %v", err)
-			continue
-		}
+	// Stop consuming on SIGINT/SIGTERM and give the messages in progress time to finish
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-		gzipReader, err := gzip.NewReader(&compressedBuffer)
-		if err != nil {
-			log.Printf("Error creating gzip reader: %v", err)
-			continue
-		}
+	log.Printf("Worker consuming %v with %d processors", processor.Types(), concurrency)
 
-		// Uploading the compressed file
-		if err = awsBucket.Upload(gzipReader, sourcePath); err != nil {
-			log.Printf("Error uploading compressed file: %v", err)
-			continue
-		}
+	err = processor.Run(ctx, queueClient)
+	switch {
+	case errors.Is(err, worker.ErrDrainTimeout):
+		// Cancelled jobs were retried; messages that could not be settled are redelivered once the connection closes
+		log.Printf("Drain timeout exceeded, in-flight jobs were cancelled")
+	case err != nil:
+		log.Printf("Error running the worker: %v", err)
+	}
+}
 
-		// Removing the local file after processing
-		if err = os.Remove(destinationPath); err != nil {
-			log.Printf("Error removing temporary file: %v", err)
-			continue
-		}
+// compressionLevel reads WORKER_COMPRESSION_LEVEL, leaving the level to the codec when it is unset
+func compressionLevel() *int {
+	if os.Getenv("WORKER_COMPRESSION_LEVEL") == "" {
+		return nil
 	}
+
+	level := env.Int("WORKER_COMPRESSION_LEVEL", codec.DefaultLevel)
+	return &level
 }
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/yansilvacerqueira/api-files/internal/users/entity"
+)
+
+type contextKey int
+
+const (
+	userIDKey contextKey = iota
+	roleKey
+)
+
+// ContextWithUserID returns a copy of ctx carrying the authenticated user ID
+func ContextWithUserID(ctx context.Context, userID int64) context.Context {
+	return context.WithValue(ctx, userIDKey, userID)
+}
+
+// UserIDFromContext returns the authenticated user ID injected by the middleware
+func UserIDFromContext(ctx context.Context) (int64, bool) {
+	userID, ok := ctx.Value(userIDKey).(int64)
+	return userID, ok
+}
+
+// ContextWithRole returns a copy of ctx carrying the authenticated user role
+func ContextWithRole(ctx context.Context, role entity.Role) context.Context {
+	return context.WithValue(ctx, roleKey, role)
+}
+
+// RoleFromContext returns the authenticated user role injected by the middleware
+func RoleFromContext(ctx context.Context) (entity.Role, bool) {
+	role, ok := ctx.Value(roleKey).(entity.Role)
+	return role, ok
+}
+
+// Authorize reports whether the authenticated user in ctx holds the permission
+func Authorize(ctx context.Context, permission entity.Permission) bool {
+	role, ok := RoleFromContext(ctx)
+	return ok && role.Can(permission)
+}
@@ -0,0 +1,255 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	authRepository "github.com/yansilvacerqueira/api-files/internal/auth/repository"
+	"github.com/yansilvacerqueira/api-files/internal/mail"
+	"github.com/yansilvacerqueira/api-files/internal/users/entity"
+	"github.com/yansilvacerqueira/api-files/internal/users/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	defaultMaxFailedLogins = 5
+	defaultLockoutDuration = 15 * time.Minute
+	defaultResetTokenTTL   = time.Hour
+
+	defaultVerificationTokenTTL = 24 * time.Hour
+
+	// mailTimeout bounds a mail sent after its request has been answered
+	mailTimeout = time.Minute
+)
+
+var (
+	errInvalidCredentials = errors.New("invalid email or password")
+	errAccountLocked      = errors.New("account is locked")
+	errEmailNotVerified   = errors.New("email address has not been verified")
+)
+
+// dummyPasswordHash is compared against when no account matches the email
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("dummy password for unknown accounts"), bcrypt.DefaultCost)
+	if err != nil {
+		panic(fmt.Sprintf("failed to hash dummy password: %v", err))
+	}
+	return hash
+})
+
+type Handler struct {
+	db               *sql.DB
+	logger           *log.Logger
+	repo             *repository.UserRepository
+	tokens           *TokenSigner
+	maxFailedLogins  int
+	lockoutDuration  time.Duration
+	tokensRepo       *authRepository.TokenRepository
+	mailer           mail.Sender
+	resetTokenTTL    time.Duration
+	resetPasswordURL string
+
+	verificationTokenTTL time.Duration
+	verifyEmailURL       string
+}
+
+type Config struct {
+	DB     *sql.DB
+	Logger *log.Logger
+	Tokens *TokenSigner
+	// MaxFailedLogins is the number of consecutive failed logins that locks an account, defaults to 5
+	MaxFailedLogins int
+	// LockoutDuration is how long an account stays locked after too many failed logins, defaults to 15 minutes
+	LockoutDuration time.Duration
+	// Mailer delivers password reset links
+	Mailer mail.Sender
+	// ResetTokenTTL is how long a password reset token stays valid, defaults to 1 hour
+	ResetTokenTTL time.Duration
+	// ResetPasswordURL is the page users are sent to; the token is added as the "token" query parameter
+	ResetPasswordURL string
+	// VerificationTokenTTL is how long an email verification token stays valid, defaults to 24 hours
+	VerificationTokenTTL time.Duration
+	// VerifyEmailURL is the page users confirm their email on; the token is added as the "token" query parameter
+	VerifyEmailURL string
+}
+
+type loginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type loginResponse struct {
+	Token     string                 `json:"token"`
+	TokenType string                 `json:"token_type"`
+	ExpiresAt time.Time              `json:"expires_at"`
+	User      map[string]interface{} `json:"user"`
+}
+
+func NewHandler(cfg Config) (*Handler, error) {
+	if cfg.DB == nil {
+		return nil, errors.New("database connection is required")
+	}
+	if cfg.Tokens == nil {
+		return nil, errors.New("token signer is required")
+	}
+	if cfg.Mailer == nil {
+		return nil, errors.New("mail sender is required")
+	}
+
+	logger := cfg.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	maxFailedLogins := cfg.MaxFailedLogins
+	if maxFailedLogins <= 0 {
+		maxFailedLogins = defaultMaxFailedLogins
+	}
+
+	lockoutDuration := cfg.LockoutDuration
+	if lockoutDuration <= 0 {
+		lockoutDuration = defaultLockoutDuration
+	}
+
+	resetTokenTTL := cfg.ResetTokenTTL
+	if resetTokenTTL <= 0 {
+		resetTokenTTL = defaultResetTokenTTL
+	}
+
+	verificationTokenTTL := cfg.VerificationTokenTTL
+	if verificationTokenTTL <= 0 {
+		verificationTokenTTL = defaultVerificationTokenTTL
+	}
+
+	return &Handler{
+		db:               cfg.DB,
+		logger:           logger,
+		repo:             repository.NewUserRepository(cfg.DB),
+		tokens:           cfg.Tokens,
+		maxFailedLogins:  maxFailedLogins,
+		lockoutDuration:  lockoutDuration,
+		tokensRepo:       authRepository.NewTokenRepository(cfg.DB),
+		mailer:           cfg.Mailer,
+		resetTokenTTL:    resetTokenTTL,
+		resetPasswordURL: cfg.ResetPasswordURL,
+
+		verificationTokenTTL: verificationTokenTTL,
+		verifyEmailURL:       cfg.VerifyEmailURL,
+	}, nil
+}
+
+func (h *Handler) login(w http.ResponseWriter, r *http.Request) {
+	var req loginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	user, err := h.authenticate(r.Context(), req.Email, req.Password, clientIP(r))
+	switch {
+	case errors.Is(err, errInvalidCredentials):
+		h.respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	case errors.Is(err, errEmailNotVerified):
+		h.respondWithError(w, http.StatusForbidden, err.Error())
+		return
+	case err != nil:
+		h.logger.Printf("Error authenticating user: %v", err)
+		h.respondWithError(w, http.StatusInternalServerError, "failed to log in")
+		return
+	}
+
+	token, expiresAt, err := h.tokens.Sign(user.ID, user.TokenVersion)
+	if err != nil {
+		h.logger.Printf("Error signing token for user %d: %v", user.ID, err)
+		h.respondWithError(w, http.StatusInternalServerError, "failed to log in")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, loginResponse{
+		Token:     token,
+		TokenType: "Bearer",
+		ExpiresAt: expiresAt,
+		User:      user.Sanitize(),
+	})
+}
+
+// authenticate checks the credentials and keeps the lockout state up to date. Every password check
+// used to sign a user in must go through it so failed attempts are always counted.
+func (h *Handler) authenticate(ctx context.Context, email, password, ip string) (*entity.User, error) {
+	user, err := h.repo.GetUserByEmail(ctx, strings.TrimSpace(strings.ToLower(email)))
+	if errors.Is(err, repository.ErrUserNotFound) {
+		// Spend as long as a real password check so response times do not reveal registered addresses
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		return nil, errInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The attempt is counted before the password is compared so concurrent guesses share one budget.
+	// Locked accounts get the same answer, and take as long, as a wrong password so the lock does
+	// not confirm that the address is registered.
+	allowed, err := h.repo.CountLoginAttempt(ctx, user.ID, h.maxFailedLogins, h.lockoutDuration)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		user.ValidatePassword(password)
+		return nil, errInvalidCredentials
+	}
+
+	if !user.ValidatePassword(password) {
+		locked, err := h.repo.RecordFailedLogin(ctx, user.ID, h.maxFailedLogins, h.lockoutDuration)
+		if err != nil {
+			return nil, err
+		}
+		if locked {
+			h.logger.Printf("User %d locked for %s after %d failed logins", user.ID, h.lockoutDuration, h.maxFailedLogins)
+		}
+		return nil, errInvalidCredentials
+	}
+
+	// Checked after the password so the answer does not reveal which addresses are registered
+	if !user.IsEmailVerified() {
+		err = h.repo.ClearFailedLogins(ctx, user.ID)
+	} else {
+		err = h.repo.RecordLogin(ctx, user.ID, ip)
+	}
+	// A concurrent attempt may have locked the account after this one was counted
+	if errors.Is(err, repository.ErrUserLocked) {
+		return nil, errInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsEmailVerified() {
+		return nil, errEmailNotVerified
+	}
+
+	user.UpdateLastLogin()
+	user.FailedLoginAttempts = 0
+	user.LockedUntil = nil
+	user.LockoutActive = false
+	user.Status.LoginCount++
+	user.Status.LastLoginIP = ip
+
+	return user, nil
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/yansilvacerqueira/api-files/internal/users/entity"
+	"github.com/yansilvacerqueira/api-files/internal/users/repository"
+)
+
+// Middleware rejects requests without a valid bearer token and injects the token's user ID and
+// current role into the request context. The user is loaded on every request so deleted accounts,
+// role changes and password changes take effect before the token expires.
+func (h *Handler) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r)
+		if !ok {
+			h.respondWithError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		claims, err := h.tokens.Verify(token)
+		if err != nil {
+			message := "invalid token"
+			if errors.Is(err, ErrExpiredToken) {
+				message = err.Error()
+			}
+			h.respondWithError(w, http.StatusUnauthorized, message)
+			return
+		}
+
+		userID, err := claims.UserID()
+		if err != nil {
+			h.respondWithError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+
+		user, err := h.repo.GetUserByID(r.Context(), userID)
+		if errors.Is(err, repository.ErrUserNotFound) {
+			h.respondWithError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+		if err != nil {
+			h.logger.Printf("Error loading authenticated user %d: %v", userID, err)
+			h.respondWithError(w, http.StatusInternalServerError, "failed to authenticate")
+			return
+		}
+
+		// A password change revokes every token issued before it
+		if !user.AcceptsTokenVersion(claims.Version) {
+			h.respondWithError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+
+		if user.Status.IsLocked {
+			h.respondWithError(w, http.StatusLocked, errAccountLocked.Error())
+			return
+		}
+
+		// Changing the email clears the verification, which must also stop tokens issued before
+		if !user.IsEmailVerified() {
+			h.respondWithError(w, http.StatusForbidden, errEmailNotVerified.Error())
+			return
+		}
+
+		ctx := ContextWithUserID(r.Context(), user.ID)
+		ctx = ContextWithRole(ctx, user.Role)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequireAuth is Middleware for a single handler function
+func (h *Handler) RequireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return h.Middleware(next).ServeHTTP
+}
+
+// RequirePermission authenticates the request and rejects it with 403 unless the user's role grants permission
+func (h *Handler) RequirePermission(permission entity.Permission, next http.HandlerFunc) http.HandlerFunc {
+	return h.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		if !Authorize(r.Context(), permission) {
+			h.respondWithError(w, http.StatusForbidden, "forbidden")
+			return
+		}
+
+		next(w, r)
+	})
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
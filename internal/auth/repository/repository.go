@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrTokenNotFound = errors.New("token is invalid or has expired")
+)
+
+type TokenRepository struct {
+	db *sql.DB
+}
+
+func NewTokenRepository(db *sql.DB) *TokenRepository {
+	return &TokenRepository{db: db}
+}
+
+// CreateResetToken stores the hash of a password reset token for the user that expires after ttl.
+// The expiry is computed by the database, whose clock is the one it is checked against.
+func (r *TokenRepository) CreateResetToken(ctx context.Context, userID int64, tokenHash string, ttl time.Duration) error {
+	query := `
+		INSERT INTO password_reset_tokens (user_id, token_hash, expires_at)
+		VALUES ($1, $2, NOW() + $3 * INTERVAL '1 second')
+	`
+
+	_, err := r.db.ExecContext(ctx, query, userID, tokenHash, ttl.Seconds())
+	return err
+}
+
+// ResetPassword consumes an unused, unexpired reset token and stores the new password hash in one
+// transaction. Every other outstanding token of the user is invalidated, temporary lockouts are
+// cleared and bearer tokens issued before the reset stop being accepted.
+func (r *TokenRepository) ResetPassword(ctx context.Context, tokenHash string, passwordHash []byte) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var userID int64
+	err = tx.QueryRowContext(ctx, `
+		UPDATE password_reset_tokens
+		SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id
+	`, tokenHash).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, ErrTokenNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE password_reset_tokens
+		SET used_at = NOW()
+		WHERE user_id = $1 AND used_at IS NULL
+	`, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE users
+		SET password = $1, updated_at = NOW(), password_changed_at = NOW(),
+			token_version = token_version + 1, failed_login_attempts = 0, locked_until = NULL
+		WHERE id = $2 AND deleted = false
+	`, passwordHash, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if rows == 0 {
+		return 0, ErrTokenNotFound
+	}
+
+	return userID, tx.Commit()
+}
+
+// CreateVerificationToken stores the hash of an email verification token for the user that expires
+// after ttl, computed by the database like CreateResetToken
+func (r *TokenRepository) CreateVerificationToken(ctx context.Context, userID int64, tokenHash string, ttl time.Duration) error {
+	query := `
+		INSERT INTO email_verification_tokens (user_id, token_hash, expires_at)
+		VALUES ($1, $2, NOW() + $3 * INTERVAL '1 second')
+	`
+
+	_, err := r.db.ExecContext(ctx, query, userID, tokenHash, ttl.Seconds())
+	return err
+}
+
+// VerifyEmail consumes an unused, unexpired verification token and marks the user's email as
+// verified in one transaction, invalidating every other outstanding verification token of the user.
+func (r *TokenRepository) VerifyEmail(ctx context.Context, tokenHash string) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var userID int64
+	err = tx.QueryRowContext(ctx, `
+		UPDATE email_verification_tokens
+		SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id
+	`, tokenHash).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, ErrTokenNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE email_verification_tokens
+		SET used_at = NOW()
+		WHERE user_id = $1 AND used_at IS NULL
+	`, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE users
+		SET email_verified_at = COALESCE(email_verified_at, NOW()), updated_at = NOW()
+		WHERE id = $1 AND deleted = false
+	`, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if rows == 0 {
+		return 0, ErrTokenNotFound
+	}
+
+	return userID, tx.Commit()
+}
@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	authRepository "github.com/yansilvacerqueira/api-files/internal/auth/repository"
+	"github.com/yansilvacerqueira/api-files/internal/mail"
+	"github.com/yansilvacerqueira/api-files/internal/users/entity"
+	"github.com/yansilvacerqueira/api-files/internal/users/repository"
+)
+
+type forgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// forgotPassword mails a reset token when the email belongs to an account. The response is the
+// same either way so the endpoint cannot be used to discover registered addresses.
+func (h *Handler) forgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req forgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	ctx := r.Context()
+	user, err := h.repo.GetUserByEmail(ctx, strings.TrimSpace(strings.ToLower(req.Email)))
+	switch {
+	case errors.Is(err, repository.ErrUserNotFound):
+	case err != nil:
+		h.logger.Printf("Error fetching user for password reset: %v", err)
+		h.respondWithError(w, http.StatusInternalServerError, "failed to request password reset")
+		return
+	default:
+		h.sendInBackground(ctx, user, "password reset", h.sendResetToken)
+	}
+
+	h.respondWithJSON(w, http.StatusAccepted, map[string]string{
+		"message": "if the email belongs to an account, a reset link has been sent",
+	})
+}
+
+func (h *Handler) resetPassword(w http.ResponseWriter, r *http.Request) {
+	var req resetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	if req.Token == "" {
+		h.respondWithError(w, http.StatusBadRequest, authRepository.ErrTokenNotFound.Error())
+		return
+	}
+
+	// SetPassword applies the same password rules as registration before anything is consumed
+	var candidate entity.User
+	if err := candidate.SetPassword(req.Password); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userID, err := h.tokensRepo.ResetPassword(r.Context(), hashToken(req.Token), candidate.Password)
+	if errors.Is(err, authRepository.ErrTokenNotFound) {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		h.logger.Printf("Error resetting password: %v", err)
+		h.respondWithError(w, http.StatusInternalServerError, "failed to reset password")
+		return
+	}
+
+	h.logger.Printf("Password reset for user %d", userID)
+	h.respondWithJSON(w, http.StatusOK, map[string]string{"message": "password has been reset"})
+}
+
+func (h *Handler) sendResetToken(ctx context.Context, user *entity.User) error {
+	token, err := newOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	if err := h.tokensRepo.CreateResetToken(ctx, user.ID, hashToken(token), h.resetTokenTTL); err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf(
+		"Hello %s,\n\nUse the link below to choose a new password. It expires in %s and can only be used once.\n\n%s\n\nIf you did not ask for a password reset you can ignore this email.\n",
+		user.FullName,
+		h.resetTokenTTL,
+		linkWithToken(h.resetPasswordURL, token),
+	)
+
+	return h.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body:    body,
+	})
+}
+
+// sendInBackground mails the user outside the request, so neither the time the mail takes nor its
+// failure tells the caller that the address is registered. Failures are only logged.
+func (h *Handler) sendInBackground(ctx context.Context, user *entity.User, what string, send func(context.Context, *entity.User) error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), mailTimeout)
+	go func() {
+		defer cancel()
+		if err := send(ctx, user); err != nil {
+			h.logger.Printf("Error sending %s to user %d: %v", what, user.ID, err)
+		}
+	}()
+}
+
+// newOpaqueToken returns a random URL-safe token; only its hash is ever stored
+func newOpaqueToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// linkWithToken adds the token as a query parameter to base, or returns the bare token without a base URL
+func linkWithToken(base, token string) string {
+	if base == "" {
+		return token
+	}
+
+	u, err := url.Parse(base)
+	if err != nil {
+		return token
+	}
+
+	query := u.Query()
+	query.Set("token", token)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"net/http"
+)
+
+func (h *Handler) SetRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/api/auth/login", h.handleLogin)
+	mux.HandleFunc("/api/auth/forgot-password", h.handleForgotPassword)
+	mux.HandleFunc("/api/auth/reset-password", h.handleResetPassword)
+	mux.HandleFunc("/api/auth/verify-email", h.handleVerifyEmail)
+	mux.HandleFunc("/api/auth/resend-verification", h.handleResendVerification)
+}
+
+func (h *Handler) handleLogin(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		h.login(w, r)
+	default:
+		h.respondWithError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (h *Handler) handleForgotPassword(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		h.forgotPassword(w, r)
+	default:
+		h.respondWithError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (h *Handler) handleResetPassword(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		h.resetPassword(w, r)
+	default:
+		h.respondWithError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (h *Handler) handleVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodPost:
+		h.verifyEmail(w, r)
+	default:
+		h.respondWithError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (h *Handler) handleResendVerification(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		h.resendVerification(w, r)
+	default:
+		h.respondWithError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
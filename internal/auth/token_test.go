@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenSignerCarriesTokenVersion(t *testing.T) {
+	signer, err := NewTokenSigner([]byte(strings.Repeat("s", minSecretLength)), time.Hour)
+	if err != nil {
+		t.Fatalf("NewTokenSigner: %v", err)
+	}
+
+	token, _, err := signer.Sign(42, 3)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	claims, err := signer.Verify(token)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if claims.Version != 3 {
+		t.Errorf("got token version %d, want 3", claims.Version)
+	}
+	if id, err := claims.UserID(); err != nil || id != 42 {
+		t.Errorf("got user ID %d (%v), want 42", id, err)
+	}
+}
+
+func TestTokenSignerRejectsTamperedVersion(t *testing.T) {
+	signer, err := NewTokenSigner([]byte(strings.Repeat("s", minSecretLength)), time.Hour)
+	if err != nil {
+		t.Fatalf("NewTokenSigner: %v", err)
+	}
+
+	token, _, err := signer.Sign(42, 3)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	other, _, err := signer.Sign(42, 4)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	// The payload of one token with the signature of another
+	parts, otherParts := strings.Split(token, "."), strings.Split(other, ".")
+	if _, err := signer.Verify(parts[0] + "." + otherParts[1] + "." + parts[2]); err != ErrInvalidToken {
+		t.Errorf("got error %v, want ErrInvalidToken", err)
+	}
+}
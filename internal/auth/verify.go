@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	authRepository "github.com/yansilvacerqueira/api-files/internal/auth/repository"
+	"github.com/yansilvacerqueira/api-files/internal/mail"
+	"github.com/yansilvacerqueira/api-files/internal/users/entity"
+	"github.com/yansilvacerqueira/api-files/internal/users/repository"
+)
+
+type verifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+type resendVerificationRequest struct {
+	Email string `json:"email"`
+}
+
+// SendVerification mails a new email verification link to the user
+func (h *Handler) SendVerification(ctx context.Context, user *entity.User) error {
+	token, err := newOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	if err := h.tokensRepo.CreateVerificationToken(ctx, user.ID, hashToken(token), h.verificationTokenTTL); err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf(
+		"Hello %s,\n\nConfirm your email address with the link below. It expires in %s.\n\n%s\n\nIf you did not create an account you can ignore this email.\n",
+		user.FullName,
+		h.verificationTokenTTL,
+		linkWithToken(h.verifyEmailURL, token),
+	)
+
+	return h.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body:    body,
+	})
+}
+
+// verifyEmail accepts the token as a "token" query parameter or in a JSON body
+func (h *Handler) verifyEmail(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if r.Method == http.MethodPost {
+		var req verifyEmailRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.respondWithError(w, http.StatusBadRequest, "invalid request payload")
+			return
+		}
+		token = req.Token
+	}
+
+	if token == "" {
+		h.respondWithError(w, http.StatusBadRequest, authRepository.ErrTokenNotFound.Error())
+		return
+	}
+
+	userID, err := h.tokensRepo.VerifyEmail(r.Context(), hashToken(token))
+	if errors.Is(err, authRepository.ErrTokenNotFound) {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		h.logger.Printf("Error verifying email: %v", err)
+		h.respondWithError(w, http.StatusInternalServerError, "failed to verify email")
+		return
+	}
+
+	h.logger.Printf("Email verified for user %d", userID)
+	h.respondWithJSON(w, http.StatusOK, map[string]string{"message": "email verified"})
+}
+
+// resendVerification answers the same way whether or not the address is registered or already verified
+func (h *Handler) resendVerification(w http.ResponseWriter, r *http.Request) {
+	var req resendVerificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	ctx := r.Context()
+	user, err := h.repo.GetUserByEmail(ctx, strings.TrimSpace(strings.ToLower(req.Email)))
+	switch {
+	case errors.Is(err, repository.ErrUserNotFound):
+	case err != nil:
+		h.logger.Printf("Error fetching user for verification: %v", err)
+		h.respondWithError(w, http.StatusInternalServerError, "failed to resend verification")
+		return
+	case !user.IsEmailVerified():
+		h.sendInBackground(ctx, user, "verification", h.SendVerification)
+	}
+
+	h.respondWithJSON(w, http.StatusAccepted, map[string]string{
+		"message": "if the email belongs to an unverified account, a verification link has been sent",
+	})
+}
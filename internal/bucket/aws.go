@@ -1,11 +1,14 @@
 package bucket
 
 import (
+	"errors"
 	"fmt"
 	"io"
-	"os"
+	"strconv"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
@@ -17,6 +20,17 @@ type AWSconfig struct {
 	BucketUpload   string
 }
 
+// Validate checks that the configuration names a region and both buckets
+func (cfg AWSconfig) Validate() error {
+	if aws.StringValue(cfg.Config.Region) == "" {
+		return errors.New("AWS region is required")
+	}
+	if cfg.BucketDownload == "" || cfg.BucketUpload == "" {
+		return errors.New("AWS download and upload buckets are required")
+	}
+	return nil
+}
+
 // Represents an AWS session for S3 bucket operations
 type AWSSession struct {
 	session        *session.Session
@@ -24,28 +38,78 @@ type AWSSession struct {
 	bucketUpload   string
 }
 
-// Download method - Downloads a file from S3 bucket to the specified destination
-func (awsSession *AWSSession) Download(src string, dest string) (*os.File, error) {
-	// Create a file for the destination
-	file, err := os.Create(dest)
+// Open method - Streams a whole object from the S3 download bucket
+func (awsSession *AWSSession) Open(key string) (*Object, error) {
+	return awsSession.getObject(key, nil)
+}
+
+// OpenRange method - Streams part of an object from the S3 download bucket with a ranged GET
+func (awsSession *AWSSession) OpenRange(key string, offset, length int64) (*Object, error) {
+	return awsSession.getObject(key, aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)))
+}
+
+func (awsSession *AWSSession) getObject(key string, byteRange *string) (*Object, error) {
+	svc := s3.New(awsSession.session)
+
+	output, err := svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(awsSession.bucketDownload),
+		Key:    aws.String(key),
+		Range:  byteRange,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create destination file: %v", err)
+		if isNotFound(err) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, fmt.Errorf("error downloading file from S3: %w", err)
 	}
-	defer file.Close()
 
-	// Initialize the S3 downloader
-	downloader := s3manager.NewDownloader(awsSession.session)
+	size := aws.Int64Value(output.ContentLength)
+	return &Object{
+		ObjectInfo: ObjectInfo{
+			Size:            size,
+			ContentType:     aws.StringValue(output.ContentType),
+			ContentEncoding: aws.StringValue(output.ContentEncoding),
+		},
+		TotalSize: totalSize(aws.StringValue(output.ContentRange), size),
+		Body:      output.Body,
+	}, nil
+}
+
+// totalSize reads the complete length from a "bytes start-end/total" Content-Range; whole objects
+// have no Content-Range and are size bytes long
+func totalSize(contentRange string, size int64) int64 {
+	_, total, ok := strings.Cut(contentRange, "/")
+	if !ok {
+		return size
+	}
 
-	// Perform the download
-	_, err = downloader.Download(file, &s3.GetObjectInput{
+	value, err := strconv.ParseInt(total, 10, 64)
+	if err != nil {
+		return size
+	}
+	return value
+}
+
+// Stat method - Describes an object of the S3 download bucket without downloading it
+func (awsSession *AWSSession) Stat(key string) (*ObjectInfo, error) {
+	svc := s3.New(awsSession.session)
+
+	output, err := svc.HeadObject(&s3.HeadObjectInput{
 		Bucket: aws.String(awsSession.bucketDownload),
-		Key:    aws.String(src),
+		Key:    aws.String(key),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("error downloading file from S3: %v", err)
+		if isNotFound(err) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, fmt.Errorf("error describing file in S3: %w", err)
 	}
 
-	return file, nil
+	return &ObjectInfo{
+		Size:            aws.Int64Value(output.ContentLength),
+		ContentType:     aws.StringValue(output.ContentType),
+		ContentEncoding: aws.StringValue(output.ContentEncoding),
+	}, nil
 }
 
 // Remove (delete) method - Deletes a file from the S3 bucket
@@ -59,7 +123,7 @@ func (awsSession *AWSSession) Remove(src string) error {
 		Key:    aws.String(src),
 	})
 	if err != nil {
-		return fmt.Errorf("error deleting file from S3: %v", err)
+		return fmt.Errorf("error deleting file from S3: %w", err)
 	}
 
 	// Wait until the object no longer exists
@@ -68,37 +132,56 @@ func (awsSession *AWSSession) Remove(src string) error {
 		Key:    aws.String(src),
 	})
 	if err != nil {
-		return fmt.Errorf("error waiting for object deletion: %v", err)
+		return fmt.Errorf("error waiting for object deletion: %w", err)
 	}
 
 	return nil
 }
 
 // Upload method - Uploads a file to the S3 bucket
-func (awsSession *AWSSession) Upload(file io.Reader, key string) error {
+// The uploader sends the body in fixed-size parts, so memory stays bounded for unknown-length streams
+func (awsSession *AWSSession) Upload(file io.Reader, key string, opts UploadOptions) error {
 	// Initialize the S3 uploader
 	uploader := s3manager.NewUploader(awsSession.session)
 
-	// Perform the upload operation
-	_, err := uploader.Upload(&s3manager.UploadInput{
+	input := &s3manager.UploadInput{
 		Bucket: aws.String(awsSession.bucketUpload),
 		Key:    aws.String(key),
 		Body:   file, // Ensure the file is uploaded
-	})
+	}
+	if opts.ContentType != "" {
+		input.ContentType = aws.String(opts.ContentType)
+	}
+	if opts.ContentEncoding != "" {
+		input.ContentEncoding = aws.String(opts.ContentEncoding)
+	}
+
+	// Perform the upload operation
+	_, err := uploader.Upload(input)
 	if err != nil {
-		return fmt.Errorf("error uploading file to S3: %v", err)
+		return fmt.Errorf("error uploading file to S3: %w", err)
 	}
 
 	return nil
 }
 
+// isNotFound reports whether S3 answered that the key does not exist; HEAD requests have no body
+// so they only carry the generic "NotFound" code
+func isNotFound(err error) bool {
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		return awsErr.Code() == s3.ErrCodeNoSuchKey || awsErr.Code() == "NotFound"
+	}
+	return false
+}
+
 // Function to create a new AWS session
 // Handles AWS session initialization and configuration
 func newAWSSession(cfg AWSconfig) (*AWSSession, error) {
 	// Create a new AWS session
 	sess, err := session.NewSession(&cfg.Config)
 	if err != nil {
-		return nil, fmt.Errorf("error creating AWS session: %v", err)
+		return nil, fmt.Errorf("error creating AWS session: %w", err)
 	}
 
 	return &AWSSession{
@@ -1,21 +1,54 @@
 package bucket
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 )
 
 const (
 	// Descriptive constant name for clarity
 	AWSS3BucketProvider BucketType = iota
+	LocalBucketProvider
 )
 
 type BucketType int
 
-// Interface representing a storage bucket provider, allowing for flexibility in provider choice
+// ErrObjectNotFound is returned by providers when the requested key does not exist
+var ErrObjectNotFound = errors.New("object not found")
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Size            int64
+	ContentType     string
+	ContentEncoding string
+}
+
+// UploadOptions carries the metadata stored with an uploaded object; empty fields are left unset
+type UploadOptions struct {
+	ContentType     string
+	ContentEncoding string
+}
+
+// Object is a stored object opened for streaming; callers must close Body. Size is the length of
+// Body, TotalSize the size of the whole object, which differs from Size for ranges.
+type Object struct {
+	ObjectInfo
+	TotalSize int64
+	Body      io.ReadCloser
+}
+
+// Interface representing a storage bucket provider, allowing for flexibility in provider choice.
+// Reads come from the download bucket and writes go to the upload bucket.
 type StorageProvider interface {
-	Upload(io.Reader, string) error
-	Download(src string, dest string) (*os.File, error)
+	Upload(io.Reader, string, UploadOptions) error
+	// Open streams a whole object
+	Open(key string) (*Object, error)
+	// OpenRange streams length bytes of an object starting at offset; Size is the length of the range
+	OpenRange(key string, offset, length int64) (*Object, error)
+	Stat(key string) (*ObjectInfo, error)
 	Remove(src string) error
 }
 
@@ -25,26 +58,121 @@ type Bucket struct {
 }
 
 // Function to initialize a new Bucket instance
-// Receives a bucket type and configuration
-func NewAWSBucket(cfg AWSconfig) (*Bucket, error) {
-	awsSession, err := newAWSSession(cfg)
-	if err != nil {
-		return nil, err
+// Receives a bucket type and its matching configuration: AWSconfig or LocalConfig
+func NewBucket(bucketType BucketType, config any) (*Bucket, error) {
+	var provider StorageProvider
+
+	switch bucketType {
+	case AWSS3BucketProvider:
+		cfg, ok := config.(AWSconfig)
+		if !ok {
+			return nil, fmt.Errorf("config must be of type AWSconfig")
+		}
+		if err := cfg.Validate(); err != nil {
+			return nil, err
+		}
+
+		awsSession, err := newAWSSession(cfg)
+		if err != nil {
+			return nil, err
+		}
+		provider = awsSession
+
+	case LocalBucketProvider:
+		cfg, ok := config.(LocalConfig)
+		if !ok {
+			return nil, fmt.Errorf("config must be of type LocalConfig")
+		}
+		if err := cfg.Validate(); err != nil {
+			return nil, err
+		}
+
+		storage, err := newLocalStorage(cfg)
+		if err != nil {
+			return nil, err
+		}
+		provider = storage
+
+	default:
+		return nil, fmt.Errorf("unsupported bucket type")
 	}
 
 	return &Bucket{
-		provider: awsSession,
+		provider: provider,
 	}, nil
 }
 
+// Function to initialize a new Bucket around an existing provider
+// Lets tests inject a MemoryStorage and inspect it afterwards
+func NewBucketWithProvider(provider StorageProvider) *Bucket {
+	return &Bucket{
+		provider: provider,
+	}
+}
+
 // Upload a file to the bucket using the underlying provider
 func (b *Bucket) Upload(file io.Reader, key string) error {
-	return b.provider.Upload(file, key)
+	return b.provider.Upload(file, key, UploadOptions{})
+}
+
+// UploadWithOptions uploads a file to the bucket along with its metadata
+func (b *Bucket) UploadWithOptions(file io.Reader, key string, opts UploadOptions) error {
+	return b.provider.Upload(file, key, opts)
 }
 
-// Download a file from the bucket using the underlying provider
+// Open streams a file from the bucket using the underlying provider
+func (b *Bucket) Open(key string) (*Object, error) {
+	return b.provider.Open(key)
+}
+
+// OpenRange streams part of a file from the bucket using the underlying provider
+func (b *Bucket) OpenRange(key string, offset, length int64) (*Object, error) {
+	if offset < 0 || length <= 0 {
+		return nil, fmt.Errorf("invalid range: offset %d, length %d", offset, length)
+	}
+	return b.provider.OpenRange(key, offset, length)
+}
+
+// Stat describes a file of the bucket using the underlying provider
+func (b *Bucket) Stat(key string) (*ObjectInfo, error) {
+	return b.provider.Stat(key)
+}
+
+// Download a file from the bucket to a local destination.
+// The object is written to a temporary file next to dest that is only renamed into place once
+// complete, so a failed copy never leaves a truncated dest behind.
+// The returned file is open and positioned at its start; the caller must close it.
 func (b *Bucket) Download(src string, dest string) (*os.File, error) {
-	return b.provider.Download(src, dest)
+	object, err := b.provider.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer object.Body.Close()
+
+	file, err := os.CreateTemp(filepath.Dir(dest), ".download-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create destination file: %w", err)
+	}
+	tempName := file.Name()
+
+	if _, err := io.Copy(file, object.Body); err != nil {
+		file.Close()
+		os.Remove(tempName)
+		return nil, fmt.Errorf("error writing destination file: %w", err)
+	}
+
+	if err := os.Rename(tempName, dest); err != nil {
+		file.Close()
+		os.Remove(tempName)
+		return nil, fmt.Errorf("error moving destination file into place: %w", err)
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error rewinding destination file: %w", err)
+	}
+
+	return file, nil
 }
 
 // Remove (delete) a file from the bucket using the underlying provider
@@ -0,0 +1,100 @@
+package bucket
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var errBrokenBody = errors.New("connection reset")
+
+// brokenStorage serves objects whose body fails after the first few bytes
+type brokenStorage struct {
+	*MemoryStorage
+}
+
+func (bs brokenStorage) Open(key string) (*Object, error) {
+	object, err := bs.MemoryStorage.Open(key)
+	if err != nil {
+		return nil, err
+	}
+	object.Body = io.NopCloser(io.MultiReader(io.LimitReader(object.Body, 3), errReader{}))
+	return object, nil
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) {
+	return 0, errBrokenBody
+}
+
+func dirEntries(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("reading %s: %v", dir, err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestBucketDownload(t *testing.T) {
+	storage := NewMemoryStorage("raw", "compact")
+	storage.Put("raw", "a.txt", []byte("hello world"))
+
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "a.txt")
+
+	file, err := NewBucketWithProvider(storage).Download("a.txt", dest)
+	if err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatalf("reading download: %v", err)
+	}
+	if string(data) != "hello world" {
+		t.Errorf("got %q from the returned file", data)
+	}
+
+	if names := dirEntries(t, dir); strings.Join(names, ",") != "a.txt" {
+		t.Errorf("got %v in the destination directory, want only a.txt", names)
+	}
+}
+
+func TestBucketDownloadFailedCopy(t *testing.T) {
+	storage := NewMemoryStorage("raw", "compact")
+	storage.Put("raw", "a.txt", []byte("hello world"))
+
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(dest, []byte("previous"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewBucketWithProvider(brokenStorage{storage}).Download("a.txt", dest); !errors.Is(err, errBrokenBody) {
+		t.Fatalf("got error %v, want errBrokenBody", err)
+	}
+
+	data, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatalf("reading destination: %v", err)
+	}
+	if string(data) != "previous" {
+		t.Errorf("got %q in the destination, want it untouched", data)
+	}
+
+	if names := dirEntries(t, dir); strings.Join(names, ",") != "a.txt" {
+		t.Errorf("got %v in the destination directory, want the temporary file removed", names)
+	}
+}
@@ -0,0 +1,98 @@
+package bucket
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+// EnvConfig holds the storage settings read from environment variables
+type EnvConfig struct {
+	Provider      BucketType
+	RawBucket     string
+	CompactBucket string
+	AWS           aws.Config
+	LocalRoot     string
+}
+
+// LoadEnvConfig reads the storage settings from the environment:
+//
+//	BUCKET_PROVIDER   "aws" (default) or "local"
+//	BUCKET_RAW        bucket receiving uploads, defaults to "drive-raw"
+//	BUCKET_COMPACT    bucket receiving compressed files, defaults to "drive-compact"
+//	AWS_REGION        required for the aws provider
+//	AWS_KEY           static credentials; the default AWS credential chain is used when unset
+//	AWS_SECRET
+//	AWS_ENDPOINT      optional S3-compatible endpoint, which also enables path-style addressing
+//	BUCKET_LOCAL_ROOT root directory of the local provider, defaults to "data"
+func LoadEnvConfig() (*EnvConfig, error) {
+	provider, err := ParseBucketType(getEnvOrDefault("BUCKET_PROVIDER", "aws"))
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &EnvConfig{
+		Provider:      provider,
+		RawBucket:     getEnvOrDefault("BUCKET_RAW", "drive-raw"),
+		CompactBucket: getEnvOrDefault("BUCKET_COMPACT", "drive-compact"),
+		LocalRoot:     getEnvOrDefault("BUCKET_LOCAL_ROOT", "data"),
+		AWS: aws.Config{
+			Region: aws.String(os.Getenv("AWS_REGION")),
+		},
+	}
+
+	if key := os.Getenv("AWS_KEY"); key != "" {
+		cfg.AWS.Credentials = credentials.NewStaticCredentials(key, os.Getenv("AWS_SECRET"), "")
+	}
+
+	if endpoint := os.Getenv("AWS_ENDPOINT"); endpoint != "" {
+		cfg.AWS.Endpoint = aws.String(endpoint)
+		cfg.AWS.S3ForcePathStyle = aws.Bool(true)
+	}
+
+	return cfg, nil
+}
+
+// NewBucket builds a Bucket for the configured provider that downloads from
+// the download bucket and uploads to the upload bucket
+func (c *EnvConfig) NewBucket(download, upload string) (*Bucket, error) {
+	switch c.Provider {
+	case AWSS3BucketProvider:
+		return NewBucket(c.Provider, AWSconfig{
+			Config:         c.AWS,
+			BucketDownload: download,
+			BucketUpload:   upload,
+		})
+	case LocalBucketProvider:
+		return NewBucket(c.Provider, LocalConfig{
+			Root:           c.LocalRoot,
+			BucketDownload: download,
+			BucketUpload:   upload,
+		})
+	default:
+		return nil, fmt.Errorf("unsupported bucket type")
+	}
+}
+
+// ParseBucketType maps a provider name to its BucketType
+func ParseBucketType(name string) (BucketType, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "aws", "s3":
+		return AWSS3BucketProvider, nil
+	case "local":
+		return LocalBucketProvider, nil
+	default:
+		return 0, fmt.Errorf("unsupported bucket provider %q", name)
+	}
+}
+
+// getEnvOrDefault retrieves an environment variable or returns a default value
+func getEnvOrDefault(key, defaultValue string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,188 @@
+package bucket
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// LocalConfig configures a filesystem-backed provider. BucketDownload and BucketUpload
+// name subdirectories of Root, mirroring the raw and compact S3 buckets.
+type LocalConfig struct {
+	Root           string
+	BucketDownload string
+	BucketUpload   string
+}
+
+// Validate checks that the configuration names a root and both bucket directories
+func (cfg LocalConfig) Validate() error {
+	if cfg.Root == "" {
+		return errors.New("local storage root is required")
+	}
+	if cfg.BucketDownload == "" || cfg.BucketUpload == "" {
+		return errors.New("local download and upload buckets are required")
+	}
+	return nil
+}
+
+// Represents a local directory tree used as bucket storage
+type LocalStorage struct {
+	downloadDir string
+	uploadDir   string
+}
+
+// Open method - Streams a whole file from the download directory
+func (ls *LocalStorage) Open(key string) (*Object, error) {
+	file, info, err := ls.openFile(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Object{ObjectInfo: *info, TotalSize: info.Size, Body: file}, nil
+}
+
+// OpenRange method - Streams part of a file from the download directory
+func (ls *LocalStorage) OpenRange(key string, offset, length int64) (*Object, error) {
+	file, info, err := ls.openFile(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if offset >= info.Size {
+		file.Close()
+		return nil, fmt.Errorf("range offset %d is beyond object size %d", offset, info.Size)
+	}
+	if offset+length > info.Size {
+		length = info.Size - offset
+	}
+
+	totalSize := info.Size
+	info.Size = length
+	return &Object{
+		ObjectInfo: *info,
+		TotalSize:  totalSize,
+		Body:       sectionReadCloser{SectionReader: io.NewSectionReader(file, offset, length), Closer: file},
+	}, nil
+}
+
+// Stat method - Describes a file of the download directory
+func (ls *LocalStorage) Stat(key string) (*ObjectInfo, error) {
+	file, info, err := ls.openFile(key)
+	if err != nil {
+		return nil, err
+	}
+	file.Close()
+
+	return info, nil
+}
+
+func (ls *LocalStorage) openFile(key string) (*os.File, *ObjectInfo, error) {
+	name := ls.objectPath(ls.downloadDir, key)
+
+	file, err := os.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, ErrObjectNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("error opening local object: %w", err)
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("error describing local object: %w", err)
+	}
+	if stat.IsDir() {
+		file.Close()
+		return nil, nil, ErrObjectNotFound
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	return file, &ObjectInfo{Size: stat.Size(), ContentType: contentType}, nil
+}
+
+// sectionReadCloser reads a section of a file and closes the whole file
+type sectionReadCloser struct {
+	*io.SectionReader
+	io.Closer
+}
+
+// Remove (delete) method - Deletes a file from the download directory; missing files are not an error
+func (ls *LocalStorage) Remove(src string) error {
+	err := os.Remove(ls.objectPath(ls.downloadDir, src))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error deleting local object: %w", err)
+	}
+
+	return nil
+}
+
+// Upload method - Writes a file into the upload directory atomically, through a temporary file
+// in the same directory that is renamed into place once fully written.
+// The filesystem has nowhere to keep metadata, so opts is ignored.
+func (ls *LocalStorage) Upload(file io.Reader, key string, opts UploadOptions) error {
+	dest := ls.objectPath(ls.uploadDir, key)
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return fmt.Errorf("error creating local object directory: %w", err)
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(dest), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("error creating temporary file: %w", err)
+	}
+	tempName := temp.Name()
+
+	if _, err := io.Copy(temp, file); err != nil {
+		temp.Close()
+		os.Remove(tempName)
+		return fmt.Errorf("error writing local object: %w", err)
+	}
+
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+		os.Remove(tempName)
+		return fmt.Errorf("error syncing local object: %w", err)
+	}
+
+	if err := temp.Close(); err != nil {
+		os.Remove(tempName)
+		return fmt.Errorf("error closing local object: %w", err)
+	}
+
+	if err := os.Rename(tempName, dest); err != nil {
+		os.Remove(tempName)
+		return fmt.Errorf("error moving local object into place: %w", err)
+	}
+
+	return nil
+}
+
+// objectPath maps a key into dir; cleaning it as an absolute path first keeps ".." from escaping dir
+func (ls *LocalStorage) objectPath(dir, key string) string {
+	return filepath.Join(dir, filepath.FromSlash(path.Clean("/"+key)))
+}
+
+// Function to create a new local storage
+// Creates the download and upload directories when they do not exist
+func newLocalStorage(cfg LocalConfig) (*LocalStorage, error) {
+	storage := &LocalStorage{
+		downloadDir: filepath.Join(cfg.Root, cfg.BucketDownload),
+		uploadDir:   filepath.Join(cfg.Root, cfg.BucketUpload),
+	}
+
+	for _, dir := range []string{storage.downloadDir, storage.uploadDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("error creating local storage directory: %w", err)
+		}
+	}
+
+	return storage, nil
+}
@@ -0,0 +1,161 @@
+package bucket
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// MemoryStorage keeps objects in memory, for hermetic tests.
+// Like the other providers it downloads from BucketDownload and uploads to BucketUpload.
+type MemoryStorage struct {
+	mu             sync.RWMutex
+	bucketDownload string
+	bucketUpload   string
+	objects        map[string]map[string]memoryObject
+}
+
+type memoryObject struct {
+	data []byte
+	opts UploadOptions
+}
+
+func NewMemoryStorage(bucketDownload, bucketUpload string) *MemoryStorage {
+	return &MemoryStorage{
+		bucketDownload: bucketDownload,
+		bucketUpload:   bucketUpload,
+		objects:        make(map[string]map[string]memoryObject),
+	}
+}
+
+// Open method - Streams a whole object of the download bucket
+func (ms *MemoryStorage) Open(key string) (*Object, error) {
+	object, ok := ms.get(ms.bucketDownload, key)
+	if !ok {
+		return nil, ErrObjectNotFound
+	}
+
+	return object.open(object.data), nil
+}
+
+// OpenRange method - Streams part of an object of the download bucket
+func (ms *MemoryStorage) OpenRange(key string, offset, length int64) (*Object, error) {
+	object, ok := ms.get(ms.bucketDownload, key)
+	if !ok {
+		return nil, ErrObjectNotFound
+	}
+
+	size := int64(len(object.data))
+	if offset >= size {
+		return nil, fmt.Errorf("range offset %d is beyond object size %d", offset, size)
+	}
+	if offset+length > size {
+		length = size - offset
+	}
+
+	return object.open(object.data[offset : offset+length]), nil
+}
+
+// Stat method - Describes an object of the download bucket
+func (ms *MemoryStorage) Stat(key string) (*ObjectInfo, error) {
+	object, err := ms.Open(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &object.ObjectInfo, nil
+}
+
+// Remove (delete) method - Deletes an object from the download bucket
+func (ms *MemoryStorage) Remove(src string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	delete(ms.objects[ms.bucketDownload], src)
+	return nil
+}
+
+// Upload method - Stores an object and its metadata in the upload bucket
+func (ms *MemoryStorage) Upload(file io.Reader, key string, opts UploadOptions) error {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return fmt.Errorf("error reading upload body: %w", err)
+	}
+
+	ms.put(ms.bucketUpload, key, memoryObject{data: data, opts: opts})
+	return nil
+}
+
+// Put stores a copy of data under key in the named bucket, e.g. to seed the download bucket
+func (ms *MemoryStorage) Put(bucket, key string, data []byte) {
+	ms.put(bucket, key, memoryObject{data: bytes.Clone(data)})
+}
+
+// Object returns a copy of the object stored under key in the named bucket
+func (ms *MemoryStorage) Object(bucket, key string) ([]byte, bool) {
+	object, ok := ms.get(bucket, key)
+	if !ok {
+		return nil, false
+	}
+	return bytes.Clone(object.data), true
+}
+
+// Metadata returns the upload options stored with the object under key in the named bucket
+func (ms *MemoryStorage) Metadata(bucket, key string) (UploadOptions, bool) {
+	object, ok := ms.get(bucket, key)
+	return object.opts, ok
+}
+
+// Keys lists the keys stored in the named bucket in lexical order
+func (ms *MemoryStorage) Keys(bucket string) []string {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	keys := make([]string, 0, len(ms.objects[bucket]))
+	for key := range ms.objects[bucket] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (ms *MemoryStorage) get(bucket, key string) (memoryObject, bool) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	object, ok := ms.objects[bucket][key]
+	return object, ok
+}
+
+func (ms *MemoryStorage) put(bucket, key string, object memoryObject) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ms.objects[bucket] == nil {
+		ms.objects[bucket] = make(map[string]memoryObject)
+	}
+	ms.objects[bucket][key] = object
+}
+
+// open streams data, which is the whole object or a range of it; stored data is never modified
+// in place so it can be read without copying
+func (mo memoryObject) open(data []byte) *Object {
+	contentType := mo.opts.ContentType
+	if contentType == "" {
+		contentType = http.DetectContentType(mo.data)
+	}
+
+	return &Object{
+		ObjectInfo: ObjectInfo{
+			Size:            int64(len(data)),
+			ContentType:     contentType,
+			ContentEncoding: mo.opts.ContentEncoding,
+		},
+		TotalSize: int64(len(mo.data)),
+		Body:      io.NopCloser(bytes.NewReader(data)),
+	}
+}
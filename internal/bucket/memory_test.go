@@ -0,0 +1,120 @@
+package bucket
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func readObject(t *testing.T, object *Object) []byte {
+	t.Helper()
+	defer object.Body.Close()
+
+	data, err := io.ReadAll(object.Body)
+	if err != nil {
+		t.Fatalf("reading object: %v", err)
+	}
+	return data
+}
+
+func TestMemoryStorageOpen(t *testing.T) {
+	storage := NewMemoryStorage("raw", "compact")
+	storage.Put("raw", "a.txt", []byte("hello world"))
+
+	object, err := storage.Open("a.txt")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if object.Size != 11 || object.TotalSize != 11 {
+		t.Errorf("got size %d of %d, want 11 of 11", object.Size, object.TotalSize)
+	}
+	if object.ContentType != "text/plain; charset=utf-8" {
+		t.Errorf("got content type %q", object.ContentType)
+	}
+	if got := readObject(t, object); string(got) != "hello world" {
+		t.Errorf("got %q", got)
+	}
+
+	if _, err := storage.Open("missing"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("got error %v for a missing key, want ErrObjectNotFound", err)
+	}
+}
+
+func TestMemoryStorageOpenRange(t *testing.T) {
+	storage := NewMemoryStorage("raw", "compact")
+	storage.Put("raw", "a.txt", []byte("hello world"))
+
+	tests := []struct {
+		name           string
+		offset, length int64
+		want           string
+	}{
+		{name: "start", offset: 0, length: 5, want: "hello"},
+		{name: "middle", offset: 6, length: 3, want: "wor"},
+		{name: "past the end", offset: 6, length: 100, want: "world"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			object, err := storage.OpenRange("a.txt", tt.offset, tt.length)
+			if err != nil {
+				t.Fatalf("OpenRange: %v", err)
+			}
+			if object.Size != int64(len(tt.want)) || object.TotalSize != 11 {
+				t.Errorf("got size %d of %d, want %d of 11", object.Size, object.TotalSize, len(tt.want))
+			}
+			if got := readObject(t, object); string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := storage.OpenRange("a.txt", 11, 1); err == nil {
+		t.Error("OpenRange beyond the object succeeded")
+	}
+}
+
+func TestMemoryStorageUploadAndRemove(t *testing.T) {
+	storage := NewMemoryStorage("raw", "compact")
+	opts := UploadOptions{ContentType: "application/pdf", ContentEncoding: "gzip"}
+
+	if err := storage.Upload(bytes.NewReader([]byte("data")), "a.pdf", opts); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+
+	// Uploads land in the upload bucket, which the storage does not read from
+	if _, err := storage.Stat("a.pdf"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("got error %v reading an upload through the download bucket", err)
+	}
+	if data, ok := storage.Object("compact", "a.pdf"); !ok || string(data) != "data" {
+		t.Errorf("got %q, %v from the upload bucket", data, ok)
+	}
+	if got, _ := storage.Metadata("compact", "a.pdf"); got != opts {
+		t.Errorf("got metadata %+v, want %+v", got, opts)
+	}
+
+	storage.Put("raw", "a.pdf", []byte("raw"))
+	if err := storage.Remove("a.pdf"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if keys := storage.Keys("raw"); len(keys) != 0 {
+		t.Errorf("got keys %v after Remove", keys)
+	}
+	if keys := storage.Keys("compact"); len(keys) != 1 {
+		t.Errorf("Remove touched the upload bucket: %v", keys)
+	}
+}
+
+func TestBucketOpenRangeRejectsInvalidRanges(t *testing.T) {
+	storage := NewMemoryStorage("raw", "raw")
+	storage.Put("raw", "a.txt", []byte("hello"))
+	b := NewBucketWithProvider(storage)
+
+	if _, err := b.OpenRange("a.txt", -1, 2); err == nil {
+		t.Error("OpenRange accepted a negative offset")
+	}
+	if _, err := b.OpenRange("a.txt", 0, 0); err == nil {
+		t.Error("OpenRange accepted an empty range")
+	}
+}
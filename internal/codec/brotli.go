@@ -0,0 +1,27 @@
+package codec
+
+import (
+	"io"
+
+	"github.com/andybalholm/brotli"
+)
+
+type brotliCodec struct{}
+
+func (brotliCodec) Name() string            { return "brotli" }
+func (brotliCodec) ContentEncoding() string { return "br" }
+func (brotliCodec) Levels() (int, int)      { return brotli.BestSpeed, brotli.BestCompression }
+
+func (c brotliCodec) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	if err := ValidateLevel(c, level); err != nil {
+		return nil, err
+	}
+	if level == DefaultLevel {
+		level = brotli.DefaultCompression
+	}
+	return brotli.NewWriterLevel(w, level), nil
+}
+
+func (brotliCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(brotli.NewReader(r)), nil
+}
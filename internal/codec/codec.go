@@ -0,0 +1,88 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// DefaultLevel asks a codec for its own default compression level. It is negative so that level 0,
+// which gzip and brotli use for storing without compression, can still be requested.
+const DefaultLevel = -1
+
+// ErrInvalidLevel is returned for a level outside the range of the codec
+var ErrInvalidLevel = errors.New("invalid compression level")
+
+// Codec compresses and decompresses streams in one format
+type Codec interface {
+	// Name identifies the codec in queue messages, configuration and the files table
+	Name() string
+	// ContentEncoding is the HTTP Content-Encoding token of the format
+	ContentEncoding() string
+	// Levels is the inclusive range of levels NewWriter accepts besides DefaultLevel
+	Levels() (min, max int)
+	// NewWriter compresses into w at level, or at the codec default for DefaultLevel. Levels outside
+	// Levels are rejected with ErrInvalidLevel rather than clamped.
+	NewWriter(w io.Writer, level int) (io.WriteCloser, error)
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// ValidateLevel checks that c accepts level
+func ValidateLevel(c Codec, level int) error {
+	if level == DefaultLevel {
+		return nil
+	}
+
+	min, max := c.Levels()
+	if level < min || level > max {
+		return fmt.Errorf("%w: %s accepts levels %d to %d, got %d", ErrInvalidLevel, c.Name(), min, max, level)
+	}
+	return nil
+}
+
+var (
+	mu     sync.RWMutex
+	codecs = make(map[string]Codec)
+)
+
+// Register makes a codec available by name, replacing any codec registered under the same name
+func Register(c Codec) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	codecs[c.Name()] = c
+}
+
+// Get returns the codec registered under name
+func Get(name string) (Codec, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	c, ok := codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported codec %q", name)
+	}
+	return c, nil
+}
+
+// Names lists the registered codecs in lexical order
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(codecs))
+	for name := range codecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func init() {
+	Register(gzipCodec{})
+	Register(zstdCodec{})
+	Register(brotliCodec{})
+}
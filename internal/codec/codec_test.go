@@ -0,0 +1,113 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func roundTrip(t *testing.T, c Codec, level int, data []byte) []byte {
+	t.Helper()
+
+	var compressed bytes.Buffer
+	writer, err := c.NewWriter(&compressed, level)
+	if err != nil {
+		t.Fatalf("NewWriter(%d): %v", level, err)
+	}
+	if _, err := writer.Write(data); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reader, err := c.NewReader(bytes.NewReader(compressed.Bytes()))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	defer reader.Close()
+
+	decompressed, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if !bytes.Equal(decompressed, data) {
+		t.Fatalf("round trip at level %d changed the data", level)
+	}
+
+	return compressed.Bytes()
+}
+
+func TestGzipLevels(t *testing.T) {
+	c, err := Get("gzip")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	data := bytes.Repeat([]byte("compressible "), 1000)
+
+	if compressed := roundTrip(t, c, DefaultLevel, data); len(compressed) >= len(data) {
+		t.Errorf("default level produced %d bytes from %d", len(compressed), len(data))
+	}
+	// Level 0 is a real level that stores the data instead of meaning "default"
+	if stored := roundTrip(t, c, 0, data); len(stored) <= len(data) {
+		t.Errorf("level 0 produced %d bytes from %d, want the data stored uncompressed", len(stored), len(data))
+	}
+	roundTrip(t, c, 9, data)
+
+	if _, err := c.NewWriter(io.Discard, 42); err == nil {
+		t.Error("NewWriter accepted an invalid level")
+	}
+}
+
+func TestNewWriterRejectsLevelsOutsideTheRange(t *testing.T) {
+	tests := []struct {
+		codec          string
+		valid, invalid []int
+	}{
+		{codec: "gzip", valid: []int{DefaultLevel, 0, 9}, invalid: []int{-2, 10, 42}},
+		{codec: "zstd", valid: []int{DefaultLevel, 1, 22}, invalid: []int{0, 23}},
+		{codec: "brotli", valid: []int{DefaultLevel, 0, 11}, invalid: []int{-2, 12}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.codec, func(t *testing.T) {
+			c, err := Get(tt.codec)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+
+			for _, level := range tt.valid {
+				if err := ValidateLevel(c, level); err != nil {
+					t.Errorf("ValidateLevel(%d): %v", level, err)
+				}
+			}
+			for _, level := range tt.invalid {
+				if _, err := c.NewWriter(io.Discard, level); !errors.Is(err, ErrInvalidLevel) {
+					t.Errorf("NewWriter(%d) returned %v, want ErrInvalidLevel", level, err)
+				}
+			}
+		})
+	}
+}
+
+func TestGetAndNames(t *testing.T) {
+	if got := Names(); !reflect.DeepEqual(got, []string{"brotli", "gzip", "zstd"}) {
+		t.Errorf("got codecs %v", got)
+	}
+
+	for _, name := range Names() {
+		c, err := Get(name)
+		if err != nil {
+			t.Fatalf("Get(%q): %v", name, err)
+		}
+		if c.Name() != name {
+			t.Errorf("Get(%q) returned codec %q", name, c.Name())
+		}
+	}
+
+	if _, err := Get("lzma"); err == nil {
+		t.Error("Get returned an unregistered codec")
+	}
+}
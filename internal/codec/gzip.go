@@ -0,0 +1,26 @@
+package codec
+
+import (
+	"compress/gzip"
+	"io"
+)
+
+type gzipCodec struct{}
+
+func (gzipCodec) Name() string            { return "gzip" }
+func (gzipCodec) ContentEncoding() string { return "gzip" }
+func (gzipCodec) Levels() (int, int)      { return gzip.NoCompression, gzip.BestCompression }
+
+func (c gzipCodec) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	if err := ValidateLevel(c, level); err != nil {
+		return nil, err
+	}
+	if level == DefaultLevel {
+		level = gzip.DefaultCompression
+	}
+	return gzip.NewWriterLevel(w, level)
+}
+
+func (gzipCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
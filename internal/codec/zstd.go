@@ -0,0 +1,32 @@
+package codec
+
+import (
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+type zstdCodec struct{}
+
+func (zstdCodec) Name() string            { return "zstd" }
+func (zstdCodec) ContentEncoding() string { return "zstd" }
+func (zstdCodec) Levels() (int, int)      { return 1, 22 }
+
+// NewWriter maps level onto the encoder speed closest to that zstd level
+func (c zstdCodec) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	if err := ValidateLevel(c, level); err != nil {
+		return nil, err
+	}
+	if level == DefaultLevel {
+		return zstd.NewWriter(w)
+	}
+	return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+}
+
+func (zstdCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	decoder, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return decoder.IOReadCloser(), nil
+}
@@ -0,0 +1,284 @@
+package files
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/yansilvacerqueira/api-files/internal/bucket"
+	"github.com/yansilvacerqueira/api-files/internal/codec"
+	"github.com/yansilvacerqueira/api-files/internal/files/entity"
+)
+
+var errUnsatisfiableRange = errors.New("requested range not satisfiable")
+
+// byteRange is a resolved, satisfiable range of an object
+type byteRange struct {
+	start  int64
+	length int64
+}
+
+// downloadFile streams the stored object to the client without touching local disk. A single
+// byte range is served as 206 Partial Content so interrupted downloads can resume; If-Range and
+// If-None-Match are honored against the file ETag.
+//
+// Compressed files are read from the compact bucket: clients whose Accept-Encoding allows the
+// codec receive the encoded bytes with Content-Encoding set, others get them decompressed on the fly.
+func (h *Handler) downloadFile(w http.ResponseWriter, r *http.Request, id int64) {
+	file, err := h.getAccessibleFile(r.Context(), id)
+	if err != nil {
+		h.respondWithRepoError(w, err, "failed to fetch file")
+		return
+	}
+
+	etag := fmt.Sprintf(`"%d-%d"`, file.ID, file.UpdatedAt.UnixNano())
+
+	w.Header().Set("Content-Type", file.Type)
+	w.Header().Set("Content-Disposition", contentDisposition(file.Name))
+	w.Header().Set("Last-Modified", file.UpdatedAt.UTC().Format(http.TimeFormat))
+
+	if !file.IsCompressed() {
+		h.serveObject(w, r, h.raw, file, etag, "")
+		return
+	}
+
+	fileCodec, err := codec.Get(file.Codec)
+	if err != nil {
+		h.logger.Printf("Error resolving codec of file %d: %v", id, err)
+		h.respondWithError(w, http.StatusInternalServerError, "failed to download file")
+		return
+	}
+
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	if acceptsEncoding(r.Header.Get("Accept-Encoding"), fileCodec.ContentEncoding()) {
+		// The encoded bytes are a different representation, so they get their own ETag
+		etag = fmt.Sprintf(`"%d-%d-%s"`, file.ID, file.UpdatedAt.UnixNano(), fileCodec.Name())
+		h.serveObject(w, r, h.compact, file, etag, fileCodec.ContentEncoding())
+		return
+	}
+
+	h.serveDecoded(w, r, file, fileCodec, etag)
+}
+
+// serveObject streams the object of file stored in source as is, honoring conditional and range
+// requests. A non-empty contentEncoding is sent along with the object. The length headers describe
+// the object as it was opened, so an object replaced meanwhile is never announced with a stale size.
+func (h *Handler) serveObject(w http.ResponseWriter, r *http.Request, source *bucket.Bucket, file *entity.File, etag, contentEncoding string) {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Accept-Ranges", "bytes")
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	rangeHeader := r.Header.Get("Range")
+	if ifRange := r.Header.Get("If-Range"); ifRange != "" && ifRange != etag && ifRange != w.Header().Get("Last-Modified") {
+		rangeHeader = ""
+	}
+
+	var rng *byteRange
+	if rangeHeader != "" {
+		// The size only resolves the range; what is served is described by the opened object
+		info, err := source.Stat(file.Path)
+		if err != nil {
+			h.respondWithObjectError(w, file, err)
+			return
+		}
+
+		rng, err = parseRange(rangeHeader, info.Size)
+		if errors.Is(err, errUnsatisfiableRange) {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", info.Size))
+			h.respondWithError(w, http.StatusRequestedRangeNotSatisfiable, err.Error())
+			return
+		}
+	}
+
+	status := http.StatusOK
+	var object *bucket.Object
+	var err error
+	if rng != nil {
+		status = http.StatusPartialContent
+		object, err = source.OpenRange(file.Path, rng.start, rng.length)
+	} else {
+		object, err = source.Open(file.Path)
+	}
+	if err != nil {
+		h.respondWithObjectError(w, file, err)
+		return
+	}
+	defer object.Body.Close()
+
+	if rng != nil {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", rng.start, rng.start+object.Size-1, object.TotalSize))
+	}
+	if contentEncoding != "" {
+		w.Header().Set("Content-Encoding", contentEncoding)
+	}
+	w.Header().Set("Content-Length", strconv.FormatInt(object.Size, 10))
+	w.WriteHeader(status)
+
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	if _, err := io.Copy(h.streamWriter(w), object.Body); err != nil {
+		h.logger.Printf("Error streaming file %d: %v", file.ID, err)
+	}
+}
+
+// serveDecoded streams the compact copy of file through fileCodec. The decoded length is not
+// known up front, so neither Content-Length nor ranges are offered.
+func (h *Handler) serveDecoded(w http.ResponseWriter, r *http.Request, file *entity.File, fileCodec codec.Codec, etag string) {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Accept-Ranges", "none")
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	object, err := h.compact.Open(file.Path)
+	if err != nil {
+		h.respondWithObjectError(w, file, err)
+		return
+	}
+	defer object.Body.Close()
+
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	decoder, err := fileCodec.NewReader(object.Body)
+	if err != nil {
+		h.logger.Printf("Error decoding file %d: %v", file.ID, err)
+		h.respondWithError(w, http.StatusBadGateway, "failed to download file")
+		return
+	}
+	defer decoder.Close()
+
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := io.Copy(h.streamWriter(w), decoder); err != nil {
+		h.logger.Printf("Error streaming file %d: %v", file.ID, err)
+	}
+}
+
+// respondWithObjectError maps a missing object to 404 and other storage errors to 502, dropping
+// the headers that only describe a served object
+func (h *Handler) respondWithObjectError(w http.ResponseWriter, file *entity.File, err error) {
+	w.Header().Del("ETag")
+	w.Header().Del("Accept-Ranges")
+
+	if errors.Is(err, bucket.ErrObjectNotFound) {
+		h.respondWithError(w, http.StatusNotFound, "file content not found")
+		return
+	}
+
+	h.logger.Printf("Error opening file %d: %v", file.ID, err)
+	h.respondWithError(w, http.StatusBadGateway, "failed to download file")
+}
+
+// parseRange resolves a Range header against size. Absent, malformed and multi-range headers
+// return a nil range, which serves the whole object as RFC 9110 allows.
+func parseRange(header string, size int64) (*byteRange, error) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return nil, nil
+	}
+
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return nil, nil
+	}
+
+	// Suffix range: the last N bytes
+	if first == "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return nil, nil
+		}
+		if n == 0 || size == 0 {
+			return nil, errUnsatisfiableRange
+		}
+		if n > size {
+			n = size
+		}
+		return &byteRange{start: size - n, length: n}, nil
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return nil, nil
+	}
+
+	end := size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return nil, nil
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+
+	if start >= size {
+		return nil, errUnsatisfiableRange
+	}
+
+	return &byteRange{start: start, length: end - start + 1}, nil
+}
+
+// acceptsEncoding reports whether an Accept-Encoding header allows encoding. Codings listed
+// with q=0 are refused; a missing header only allows the identity coding.
+func acceptsEncoding(header, encoding string) bool {
+	accepted := false
+	for _, candidate := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(candidate, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != encoding && name != "*" {
+			continue
+		}
+
+		refused := false
+		for _, param := range strings.Split(params, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.EqualFold(key, "q") {
+				q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				refused = err != nil || q == 0
+			}
+		}
+
+		// An explicit entry for the coding wins over the wildcard
+		if name == encoding {
+			return !refused
+		}
+		accepted = !refused
+	}
+	return accepted
+}
+
+// etagMatches implements the weak comparison If-None-Match uses
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func contentDisposition(name string) string {
+	if value := mime.FormatMediaType("attachment", map[string]string{"filename": name}); value != "" {
+		return value
+	}
+	return "attachment"
+}
@@ -0,0 +1,208 @@
+package files
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yansilvacerqueira/api-files/internal/bucket"
+	"github.com/yansilvacerqueira/api-files/internal/files/entity"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		size    int64
+		want    *byteRange
+		wantErr error
+	}{
+		{name: "no header", header: "", size: 100},
+		{name: "closed range", header: "bytes=0-49", size: 100, want: &byteRange{start: 0, length: 50}},
+		{name: "single byte", header: "bytes=99-99", size: 100, want: &byteRange{start: 99, length: 1}},
+		{name: "open range", header: "bytes=50-", size: 100, want: &byteRange{start: 50, length: 50}},
+		{name: "end past the size", header: "bytes=90-200", size: 100, want: &byteRange{start: 90, length: 10}},
+		{name: "suffix", header: "bytes=-10", size: 100, want: &byteRange{start: 90, length: 10}},
+		{name: "suffix longer than the object", header: "bytes=-200", size: 100, want: &byteRange{start: 0, length: 100}},
+		{name: "spaces around the spec", header: "bytes= 10-19 ", size: 100, want: &byteRange{start: 10, length: 10}},
+		{name: "start at the size", header: "bytes=100-", size: 100, wantErr: errUnsatisfiableRange},
+		{name: "empty suffix", header: "bytes=-0", size: 100, wantErr: errUnsatisfiableRange},
+		{name: "suffix of an empty object", header: "bytes=-5", size: 0, wantErr: errUnsatisfiableRange},
+		{name: "range of an empty object", header: "bytes=0-", size: 0, wantErr: errUnsatisfiableRange},
+		{name: "multiple ranges", header: "bytes=0-1,5-6", size: 100},
+		{name: "other unit", header: "items=0-1", size: 100},
+		{name: "end before start", header: "bytes=5-2", size: 100},
+		{name: "missing dash", header: "bytes=5", size: 100},
+		{name: "only a dash", header: "bytes=-", size: 100},
+		{name: "negative start", header: "bytes=-5-10", size: 100},
+		{name: "not a number", header: "bytes=a-b", size: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRange(tt.header, tt.size)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+
+			switch {
+			case tt.want == nil && got != nil:
+				t.Errorf("got %+v, want the whole object", *got)
+			case tt.want != nil && got == nil:
+				t.Errorf("got the whole object, want %+v", *tt.want)
+			case tt.want != nil && *got != *tt.want:
+				t.Errorf("got %+v, want %+v", *got, *tt.want)
+			}
+		})
+	}
+}
+
+func TestETagMatches(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{header: "", want: false},
+		{header: `"1-2"`, want: true},
+		{header: `W/"1-2"`, want: true},
+		{header: `"other", "1-2"`, want: true},
+		{header: "*", want: true},
+		{header: `"1-3"`, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, `"1-2"`); got != tt.want {
+			t.Errorf("etagMatches(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func newTestHandler() *Handler {
+	return &Handler{logger: log.New(io.Discard, "", 0), streamTimeout: time.Minute}
+}
+
+func TestServeObject(t *testing.T) {
+	storage := bucket.NewMemoryStorage("raw", "raw")
+	storage.Put("raw", "uploads/a.txt", []byte("hello world"))
+	source := bucket.NewBucketWithProvider(storage)
+	file := &entity.File{ID: 1, Path: "uploads/a.txt"}
+
+	tests := []struct {
+		name       string
+		method     string
+		headers    map[string]string
+		wantStatus int
+		wantBody   string
+		wantRange  string
+		wantLength string
+	}{
+		{name: "whole object", method: http.MethodGet, wantStatus: http.StatusOK, wantBody: "hello world", wantLength: "11"},
+		{name: "head", method: http.MethodHead, wantStatus: http.StatusOK, wantLength: "11"},
+		{
+			name:       "range",
+			method:     http.MethodGet,
+			headers:    map[string]string{"Range": "bytes=6-"},
+			wantStatus: http.StatusPartialContent,
+			wantBody:   "world",
+			wantRange:  "bytes 6-10/11",
+			wantLength: "5",
+		},
+		{
+			name:       "unsatisfiable range",
+			method:     http.MethodGet,
+			headers:    map[string]string{"Range": "bytes=20-"},
+			wantStatus: http.StatusRequestedRangeNotSatisfiable,
+			wantRange:  "bytes */11",
+		},
+		{
+			name:       "stale If-Range",
+			method:     http.MethodGet,
+			headers:    map[string]string{"Range": "bytes=6-", "If-Range": `"old"`},
+			wantStatus: http.StatusOK,
+			wantBody:   "hello world",
+			wantLength: "11",
+		},
+		{
+			name:       "matching If-None-Match",
+			method:     http.MethodGet,
+			headers:    map[string]string{"If-None-Match": `"1-1"`},
+			wantStatus: http.StatusNotModified,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/api/files/1/content", nil)
+			for key, value := range tt.headers {
+				r.Header.Set(key, value)
+			}
+			w := httptest.NewRecorder()
+
+			newTestHandler().serveObject(w, r, source, file, `"1-1"`, "")
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("got body %q, want %q", w.Body.String(), tt.wantBody)
+			}
+			if got := w.Header().Get("Content-Range"); got != tt.wantRange {
+				t.Errorf("got Content-Range %q, want %q", got, tt.wantRange)
+			}
+			if tt.wantLength != "" && w.Header().Get("Content-Length") != tt.wantLength {
+				t.Errorf("got Content-Length %q, want %q", w.Header().Get("Content-Length"), tt.wantLength)
+			}
+		})
+	}
+}
+
+func TestServeObjectMissingObject(t *testing.T) {
+	source := bucket.NewBucketWithProvider(bucket.NewMemoryStorage("raw", "raw"))
+	file := &entity.File{ID: 1, Path: "uploads/missing.txt"}
+
+	for _, rangeHeader := range []string{"", "bytes=0-1"} {
+		r := httptest.NewRequest(http.MethodGet, "/api/files/1/content", nil)
+		if rangeHeader != "" {
+			r.Header.Set("Range", rangeHeader)
+		}
+		w := httptest.NewRecorder()
+
+		newTestHandler().serveObject(w, r, source, file, `"1-1"`, "")
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Range %q: got status %d, want 404", rangeHeader, w.Code)
+		}
+		if w.Header().Get("ETag") != "" || w.Header().Get("Accept-Ranges") != "" {
+			t.Errorf("Range %q: error response kept the object headers %v", rangeHeader, w.Header())
+		}
+	}
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{header: "", want: false},
+		{header: "gzip", want: true},
+		{header: "deflate, gzip;q=0.5", want: true},
+		{header: "GZIP", want: true},
+		{header: "gzip;q=0", want: false},
+		{header: "*", want: true},
+		{header: "*;q=0", want: false},
+		{header: "*, gzip;q=0", want: false},
+		{header: "gzip;q=0.8, *;q=0", want: true},
+		{header: "br, zstd", want: false},
+		{header: "gzip;q=abc", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := acceptsEncoding(tt.header, "gzip"); got != tt.want {
+			t.Errorf("acceptsEncoding(%q, gzip) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
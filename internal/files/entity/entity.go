@@ -0,0 +1,139 @@
+package entity
+
+import (
+	"errors"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	maxNameLength = 60
+	maxTypeLength = 50
+	maxPathLength = 250
+
+	thumbnailPrefix = "thumbnails"
+)
+
+var (
+	ErrEmptyName   = errors.New("file name is required")
+	ErrNameTooLong = errors.New("file name must be at most 60 characters long")
+	ErrInvalidName = errors.New("file name must not contain slashes")
+	ErrDotName     = errors.New(`file name must not be "." or ".."`)
+	ErrEmptyType   = errors.New("file type is required")
+	ErrTypeTooLong = errors.New("file type must be at most 50 characters long")
+	ErrEmptyPath   = errors.New("file path is required")
+	ErrPathTooLong = errors.New("file path must be at most 250 characters long")
+)
+
+type File struct {
+	ID        int64
+	OwnerID   *int64
+	FolderID  *int64
+	Name      string
+	Type      string
+	Path      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Deleted   bool
+	// Codec names the compression of the compact copy; empty until the worker has compressed the file
+	Codec string
+	// Checksum is the hex SHA-256 of the raw upload, computed by the worker
+	Checksum string
+}
+
+func NewFile(ownerID, folderID *int64, name, fileType, path string) (*File, error) {
+	now := time.Now()
+
+	name = strings.TrimSpace(name)
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+
+	fileType = strings.TrimSpace(fileType)
+	if fileType == "" {
+		return nil, ErrEmptyType
+	}
+	if len(fileType) > maxTypeLength {
+		return nil, ErrTypeTooLong
+	}
+
+	if path == "" {
+		return nil, ErrEmptyPath
+	}
+	if len(path) > maxPathLength {
+		return nil, ErrPathTooLong
+	}
+
+	return &File{
+		OwnerID:   ownerID,
+		FolderID:  folderID,
+		Name:      name,
+		Type:      fileType,
+		Path:      path,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}, nil
+}
+
+func (f *File) Rename(name string) error {
+	name = strings.TrimSpace(name)
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+
+	f.Name = name
+	f.UpdatedAt = time.Now()
+	return nil
+}
+
+// MoveTo places the file in folderID; a nil folderID moves the file to the root.
+func (f *File) MoveTo(folderID *int64) {
+	f.FolderID = folderID
+	f.UpdatedAt = time.Now()
+}
+
+// ThumbnailKey is where the worker stores the thumbnail of the object key in the compact bucket
+func ThumbnailKey(key string) string {
+	return path.Join(thumbnailPrefix, key) + ".jpg"
+}
+
+func (f *File) IsCompressed() bool {
+	return f.Codec != ""
+}
+
+func (f *File) IsDeleted() bool {
+	return f.Deleted
+}
+
+func ValidateName(name string) error {
+	if name == "" {
+		return ErrEmptyName
+	}
+	if len([]rune(name)) > maxNameLength {
+		return ErrNameTooLong
+	}
+	if strings.ContainsAny(name, `/\`) {
+		return ErrInvalidName
+	}
+	// Object keys are joined as paths, where these names would point at another key
+	if name == "." || name == ".." {
+		return ErrDotName
+	}
+	return nil
+}
+
+func (f *File) Sanitize() map[string]interface{} {
+	return map[string]interface{}{
+		"id":         f.ID,
+		"owner_id":   f.OwnerID,
+		"folder_id":  f.FolderID,
+		"name":       f.Name,
+		"type":       f.Type,
+		"created_at": f.CreatedAt,
+		"updated_at": f.UpdatedAt,
+		"compressed": f.IsCompressed(),
+		"codec":      f.Codec,
+		"checksum":   f.Checksum,
+	}
+}
@@ -0,0 +1,48 @@
+package entity
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateName(t *testing.T) {
+	tests := []struct {
+		name string
+		want error
+	}{
+		{name: "report.pdf"},
+		{name: "..hidden"},
+		{name: "a..b"},
+		{name: "", want: ErrEmptyName},
+		{name: strings.Repeat("a", 61), want: ErrNameTooLong},
+		{name: "a/b", want: ErrInvalidName},
+		{name: `a\b`, want: ErrInvalidName},
+		{name: ".", want: ErrDotName},
+		{name: "..", want: ErrDotName},
+	}
+
+	for _, tt := range tests {
+		if err := ValidateName(tt.name); !errors.Is(err, tt.want) {
+			t.Errorf("ValidateName(%q) = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestNewFileRejectsDotNames(t *testing.T) {
+	for _, name := range []string{".", "..", " .. "} {
+		if _, err := NewFile(nil, nil, name, "text/plain", "uploads/x/"+name); !errors.Is(err, ErrDotName) {
+			t.Errorf("NewFile(%q) = %v, want ErrDotName", name, err)
+		}
+	}
+}
+
+func TestRenameRejectsDotNames(t *testing.T) {
+	file := &File{Name: "report.pdf"}
+	if err := file.Rename(".."); !errors.Is(err, ErrDotName) {
+		t.Errorf("Rename(..) = %v, want ErrDotName", err)
+	}
+	if file.Name != "report.pdf" {
+		t.Errorf("a rejected rename changed the name to %q", file.Name)
+	}
+}
@@ -0,0 +1,291 @@
+package files
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/yansilvacerqueira/api-files/internal/auth"
+	"github.com/yansilvacerqueira/api-files/internal/bucket"
+	"github.com/yansilvacerqueira/api-files/internal/files/entity"
+	"github.com/yansilvacerqueira/api-files/internal/files/repository"
+	folderRepository "github.com/yansilvacerqueira/api-files/internal/folders/repository"
+	jobEntity "github.com/yansilvacerqueira/api-files/internal/jobs/entity"
+	jobRepository "github.com/yansilvacerqueira/api-files/internal/jobs/repository"
+	"github.com/yansilvacerqueira/api-files/internal/queue"
+	userEntity "github.com/yansilvacerqueira/api-files/internal/users/entity"
+)
+
+const (
+	defaultMaxUploadSize = 1 << 30 // 1 GiB
+	defaultStreamTimeout = time.Minute
+)
+
+type Handler struct {
+	db            *sql.DB
+	logger        *log.Logger
+	repo          *repository.FileRepository
+	folders       *folderRepository.FolderRepository
+	jobs          *jobRepository.JobRepository
+	raw           *bucket.Bucket
+	compact       *bucket.Bucket
+	queue         *queue.Queue
+	auth          *auth.Handler
+	maxUploadSize int64
+	streamTimeout time.Duration
+}
+
+type Config struct {
+	DB     *sql.DB
+	Logger *log.Logger
+	Auth   *auth.Handler
+	// Raw receives uploads and serves files until they are compressed; the worker reads uploads from it
+	Raw *bucket.Bucket
+	// Compact serves the compressed copies the worker writes
+	Compact *bucket.Bucket
+	// Queue receives the jobs of every upload and deletion so the worker can process them
+	Queue *queue.Queue
+	// MaxUploadSize limits the request body of uploads in bytes, defaults to 1 GiB
+	MaxUploadSize int64
+	// StreamTimeout is how long an upload or download may stall before its connection is cut,
+	// defaults to 1m; transfers themselves are not limited by the server timeouts
+	StreamTimeout time.Duration
+}
+
+type renameFileRequest struct {
+	Name string `json:"name"`
+}
+
+// moveFileRequest moves a file into FolderID, or to the root when FolderID is null
+type moveFileRequest struct {
+	FolderID *int64 `json:"folder_id"`
+}
+
+func NewHandler(cfg Config) (*Handler, error) {
+	if cfg.DB == nil {
+		return nil, errors.New("database connection is required")
+	}
+	if cfg.Auth == nil {
+		return nil, errors.New("auth handler is required")
+	}
+	if cfg.Raw == nil {
+		return nil, errors.New("raw bucket is required")
+	}
+	if cfg.Compact == nil {
+		return nil, errors.New("compact bucket is required")
+	}
+	if cfg.Queue == nil {
+		return nil, errors.New("queue is required")
+	}
+
+	logger := cfg.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	maxUploadSize := cfg.MaxUploadSize
+	if maxUploadSize <= 0 {
+		maxUploadSize = defaultMaxUploadSize
+	}
+
+	streamTimeout := cfg.StreamTimeout
+	if streamTimeout <= 0 {
+		streamTimeout = defaultStreamTimeout
+	}
+
+	return &Handler{
+		db:            cfg.DB,
+		logger:        logger,
+		repo:          repository.NewFileRepository(cfg.DB),
+		folders:       folderRepository.NewFolderRepository(cfg.DB),
+		jobs:          jobRepository.NewJobRepository(cfg.DB),
+		raw:           cfg.Raw,
+		compact:       cfg.Compact,
+		queue:         cfg.Queue,
+		auth:          cfg.Auth,
+		maxUploadSize: maxUploadSize,
+		streamTimeout: streamTimeout,
+	}, nil
+}
+
+// getFiles lists the files in the folder given by the folder_id query parameter, or the root files without it
+func (h *Handler) getFiles(w http.ResponseWriter, r *http.Request) {
+	var folderID *int64
+	if raw := r.URL.Query().Get("folder_id"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			h.respondWithError(w, http.StatusBadRequest, "invalid folder ID")
+			return
+		}
+		folderID = &id
+	}
+
+	ctx := r.Context()
+	if folderID != nil && !h.folderExists(w, r, *folderID) {
+		return
+	}
+
+	files, err := h.repo.GetFilesByFolder(ctx, folderID, ownerFilter(ctx))
+	if err != nil {
+		h.logger.Printf("Error fetching files: %v", err)
+		h.respondWithError(w, http.StatusInternalServerError, "failed to fetch files")
+		return
+	}
+
+	sanitizedFiles := make([]map[string]interface{}, 0, len(files))
+	for _, file := range files {
+		sanitizedFiles = append(sanitizedFiles, file.Sanitize())
+	}
+
+	h.respondWithJSON(w, http.StatusOK, sanitizedFiles)
+}
+
+func (h *Handler) getFileByID(w http.ResponseWriter, r *http.Request, id int64) {
+	file, err := h.getAccessibleFile(r.Context(), id)
+	if err != nil {
+		h.respondWithRepoError(w, err, "failed to fetch file")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, file.Sanitize())
+}
+
+func (h *Handler) renameFile(w http.ResponseWriter, r *http.Request, id int64) {
+	var req renameFileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	ctx := r.Context()
+	file, err := h.getAccessibleFile(ctx, id)
+	if err != nil {
+		h.respondWithRepoError(w, err, "failed to rename file")
+		return
+	}
+
+	if err := file.Rename(req.Name); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.repo.UpdateFile(ctx, file); err != nil {
+		h.respondWithRepoError(w, err, "failed to rename file")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, file.Sanitize())
+}
+
+func (h *Handler) moveFile(w http.ResponseWriter, r *http.Request, id int64) {
+	var req moveFileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	ctx := r.Context()
+	file, err := h.getAccessibleFile(ctx, id)
+	if err != nil {
+		h.respondWithRepoError(w, err, "failed to move file")
+		return
+	}
+
+	if req.FolderID != nil && !h.folderExists(w, r, *req.FolderID) {
+		return
+	}
+
+	file.MoveTo(req.FolderID)
+
+	// The folder may be deleted after the check above, so the repository checks it again under a lock
+	if err := h.repo.UpdateFile(ctx, file); err != nil {
+		h.respondWithRepoError(w, err, "failed to move file")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, file.Sanitize())
+}
+
+func (h *Handler) deleteFile(w http.ResponseWriter, r *http.Request, id int64) {
+	ctx := r.Context()
+	file, err := h.getAccessibleFile(ctx, id)
+	if err != nil {
+		h.respondWithRepoError(w, err, "failed to delete file")
+		return
+	}
+
+	if err := h.repo.DeleteFile(ctx, id); err != nil {
+		h.respondWithRepoError(w, err, "failed to delete file")
+		return
+	}
+
+	// The stored objects are removed by the worker; if that cannot be queued they are only orphaned
+	if _, err := h.enqueueJob(ctx, file, jobEntity.TypeCleanup); err != nil {
+		h.logger.Printf("Error enqueueing cleanup of file %d: %v", id, err)
+	}
+
+	h.respondWithJSON(w, http.StatusOK, map[string]string{"message": "file deleted successfully"})
+}
+
+// getAccessibleFile fetches a file the authenticated user may act on: their own files, or any file
+// for users allowed to manage users. Other files are reported as not found so their IDs cannot be probed.
+func (h *Handler) getAccessibleFile(ctx context.Context, id int64) (*entity.File, error) {
+	file, err := h.repo.GetFileByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if ownerID := ownerFilter(ctx); ownerID != nil && (file.OwnerID == nil || *file.OwnerID != *ownerID) {
+		return nil, repository.ErrFileNotFound
+	}
+
+	return file, nil
+}
+
+// ownerFilter returns the owner the authenticated user is limited to, or nil when they may see every file
+func ownerFilter(ctx context.Context) *int64 {
+	if auth.Authorize(ctx, userEntity.PermissionManageUsers) {
+		return nil
+	}
+
+	// RequireAuth always sets the user; an unknown user is limited to an owner no file has
+	userID, _ := auth.UserIDFromContext(ctx)
+	return &userID
+}
+
+// folderExists writes a 404 or 500 response and returns false when the folder cannot be used
+func (h *Handler) folderExists(w http.ResponseWriter, r *http.Request, folderID int64) bool {
+	_, err := h.folders.GetFolderByID(r.Context(), folderID)
+	if errors.Is(err, folderRepository.ErrFolderNotFound) {
+		h.respondWithError(w, http.StatusNotFound, "folder not found")
+		return false
+	}
+	if err != nil {
+		h.logger.Printf("Error fetching folder %d: %v", folderID, err)
+		h.respondWithError(w, http.StatusInternalServerError, "failed to fetch folder")
+		return false
+	}
+
+	return true
+}
+
+// respondWithRepoError maps ErrFileNotFound and ErrFolderNotFound to 404 and anything else to 500
+// with the given message
+func (h *Handler) respondWithRepoError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, repository.ErrFileNotFound) {
+		h.respondWithError(w, http.StatusNotFound, "file not found")
+		return
+	}
+	if errors.Is(err, repository.ErrFolderNotFound) {
+		h.respondWithError(w, http.StatusNotFound, "folder not found")
+		return
+	}
+
+	h.logger.Printf("Error: %s: %v", message, err)
+	h.respondWithError(w, http.StatusInternalServerError, message)
+}
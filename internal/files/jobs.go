@@ -0,0 +1,96 @@
+package files
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/yansilvacerqueira/api-files/internal/files/entity"
+	jobEntity "github.com/yansilvacerqueira/api-files/internal/jobs/entity"
+	"github.com/yansilvacerqueira/api-files/internal/queue"
+)
+
+// thumbnailTypes are the media types the worker can render thumbnails of
+var thumbnailTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+// followUpJobTypes lists the jobs queued after compression for a new upload
+func followUpJobTypes(file *entity.File) []string {
+	jobTypes := []string{jobEntity.TypeChecksum}
+	if thumbnailTypes[strings.ToLower(file.Type)] {
+		jobTypes = append(jobTypes, jobEntity.TypeThumbnail)
+	}
+	return jobTypes
+}
+
+// enqueueJob records a queued job of jobType for file and publishes the message the worker uses
+// to locate the raw object and report on the job. The job is marked failed if publishing fails.
+func (h *Handler) enqueueJob(ctx context.Context, file *entity.File, jobType string) (*jobEntity.Job, error) {
+	job, err := h.createJob(ctx, file, jobType)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.publishJob(file, job); err != nil {
+		if err := h.jobs.MarkFailed(ctx, job.ID, "failed to enqueue job"); err != nil {
+			h.logger.Printf("Error failing job %d: %v", job.ID, err)
+		}
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// enqueueUploadJob is enqueueJob for a job the upload cannot do without. A publish failure rolls
+// the upload back, so the job is deleted rather than left failed for a file that no longer exists.
+func (h *Handler) enqueueUploadJob(ctx context.Context, file *entity.File, jobType string) error {
+	job, err := h.createJob(ctx, file, jobType)
+	if err != nil {
+		return err
+	}
+
+	if err := h.publishJob(file, job); err != nil {
+		if err := h.jobs.DeleteJob(ctx, job.ID); err != nil {
+			h.logger.Printf("Error deleting job %d: %v", job.ID, err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (h *Handler) createJob(ctx context.Context, file *entity.File, jobType string) (*jobEntity.Job, error) {
+	job, err := jobEntity.NewJob(file.ID, jobType)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.jobs.CreateJob(ctx, job); err != nil {
+		return nil, fmt.Errorf("failed to create job: %w", err)
+	}
+
+	return job, nil
+}
+
+func (h *Handler) publishJob(file *entity.File, job *jobEntity.Job) error {
+	message, err := queue.NewQueueMessage(job.Type, queue.FilePayload{
+		Filename: path.Base(file.Path),
+		Path:     path.Dir(file.Path),
+		ID:       int(file.ID),
+		JobID:    job.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	body, err := message.ToJSON()
+	if err != nil {
+		return fmt.Errorf("failed to encode queue message: %w", err)
+	}
+
+	return h.queue.PublishMessage(body)
+}
@@ -0,0 +1,277 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/yansilvacerqueira/api-files/internal/files/entity"
+)
+
+var (
+	ErrFileNotFound   = errors.New("file not found")
+	ErrFolderNotFound = errors.New("folder not found")
+)
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+type FileRepository struct {
+	db *sql.DB
+}
+
+func NewFileRepository(db *sql.DB) *FileRepository {
+	return &FileRepository{db: db}
+}
+
+func scanFile(s scanner) (*entity.File, error) {
+	file := &entity.File{}
+	var ownerID, folderID sql.NullInt64
+	var codec, checksum sql.NullString
+
+	err := s.Scan(
+		&file.ID,
+		&ownerID,
+		&folderID,
+		&file.Name,
+		&file.Type,
+		&file.Path,
+		&file.CreatedAt,
+		&file.UpdatedAt,
+		&file.Deleted,
+		&codec,
+		&checksum,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if ownerID.Valid {
+		file.OwnerID = &ownerID.Int64
+	}
+	if folderID.Valid {
+		file.FolderID = &folderID.Int64
+	}
+	file.Codec = codec.String
+	file.Checksum = checksum.String
+
+	return file, nil
+}
+
+func (r *FileRepository) GetFileByID(ctx context.Context, id int64) (*entity.File, error) {
+	query := `
+		SELECT id, owner_id, folder_id, name, type, path, created_at, updated_at, deleted, codec, checksum
+		FROM files
+		WHERE id = $1 AND deleted = false
+	`
+
+	file, err := scanFile(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrFileNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// GetFilesByFolder lists the files directly in folderID; a nil folderID lists the root files.
+// A non-nil ownerID only lists the files of that owner.
+func (r *FileRepository) GetFilesByFolder(ctx context.Context, folderID, ownerID *int64) ([]entity.File, error) {
+	query := `
+		SELECT id, owner_id, folder_id, name, type, path, created_at, updated_at, deleted, codec, checksum
+		FROM files
+		WHERE folder_id IS NOT DISTINCT FROM $1 AND ($2::INT IS NULL OR owner_id = $2) AND deleted = false
+		ORDER BY name ASC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, nullableID(folderID), nullableID(ownerID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []entity.File
+	for rows.Next() {
+		file, err := scanFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, *file)
+	}
+
+	return files, rows.Err()
+}
+
+// CreateFile inserts the file. A folder it is put into is locked first and must still be live,
+// otherwise ErrFolderNotFound is returned.
+func (r *FileRepository) CreateFile(ctx context.Context, file *entity.File) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if file.FolderID != nil {
+		if err := lockFolder(ctx, tx, *file.FolderID); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		INSERT INTO files (owner_id, folder_id, name, type, path, created_at, updated_at, deleted)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`
+
+	err = tx.QueryRowContext(ctx, query,
+		nullableID(file.OwnerID),
+		nullableID(file.FolderID),
+		file.Name,
+		file.Type,
+		file.Path,
+		file.CreatedAt,
+		file.UpdatedAt,
+		file.Deleted,
+	).Scan(&file.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateFile stores the file name and folder, locking the folder like CreateFile
+func (r *FileRepository) UpdateFile(ctx context.Context, file *entity.File) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if file.FolderID != nil {
+		if err := lockFolder(ctx, tx, *file.FolderID); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		UPDATE files
+		SET folder_id = $1, name = $2, updated_at = $3
+		WHERE id = $4 AND deleted = false
+	`
+
+	result, err := tx.ExecContext(ctx, query,
+		nullableID(file.FolderID),
+		file.Name,
+		file.UpdatedAt,
+		file.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrFileNotFound
+	}
+
+	return tx.Commit()
+}
+
+// lockFolder takes a share lock on the live folder a file is put into. The folders repository
+// holds an update lock while it checks a folder is empty, so a concurrent delete cannot miss the file.
+func lockFolder(ctx context.Context, tx *sql.Tx, id int64) error {
+	var locked int64
+	err := tx.QueryRowContext(ctx, `
+		SELECT id FROM folders WHERE id = $1 AND deleted = false FOR SHARE
+	`, id).Scan(&locked)
+	if err == sql.ErrNoRows {
+		return ErrFolderNotFound
+	}
+
+	return err
+}
+
+// SetCodec records the codec the worker compressed the file with
+func (r *FileRepository) SetCodec(ctx context.Context, id int64, codec string) error {
+	query := `
+		UPDATE files
+		SET codec = $1
+		WHERE id = $2 AND deleted = false
+	`
+
+	result, err := r.db.ExecContext(ctx, query, codec, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrFileNotFound
+	}
+
+	return nil
+}
+
+// SetChecksum records the SHA-256 of the raw upload
+func (r *FileRepository) SetChecksum(ctx context.Context, id int64, checksum string) error {
+	query := `
+		UPDATE files
+		SET checksum = $1
+		WHERE id = $2 AND deleted = false
+	`
+
+	result, err := r.db.ExecContext(ctx, query, checksum, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrFileNotFound
+	}
+
+	return nil
+}
+
+func (r *FileRepository) DeleteFile(ctx context.Context, id int64) error {
+	query := `
+		UPDATE files
+		SET deleted = true, updated_at = NOW()
+		WHERE id = $1 AND deleted = false
+	`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrFileNotFound
+	}
+
+	return nil
+}
+
+func nullableID(id *int64) sql.NullInt64 {
+	if id == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: *id, Valid: true}
+}
@@ -0,0 +1,109 @@
+package files
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/yansilvacerqueira/api-files/internal/auth"
+	userEntity "github.com/yansilvacerqueira/api-files/internal/users/entity"
+)
+
+const basePath = "/api/files/"
+
+func (h *Handler) SetRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/api/files", h.auth.RequireAuth(h.handleFiles))
+	mux.HandleFunc("/api/files/upload", h.auth.RequireAuth(h.handleUpload))
+	mux.HandleFunc(basePath, h.auth.RequireAuth(h.handleFileByID))
+}
+
+func (h *Handler) handleFiles(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeMethod(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.getFiles(w, r)
+	default:
+		h.respondWithError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (h *Handler) handleUpload(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeMethod(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		h.uploadFile(w, r)
+	default:
+		h.respondWithError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleFileByID serves /api/files/{id}, /api/files/{id}/move, /api/files/{id}/content,
+// /api/files/{id}/thumbnail and /api/files/{id}/status
+func (h *Handler) handleFileByID(w http.ResponseWriter, r *http.Request) {
+	id, action, err := parseFilePath(r.URL.Path)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "invalid file ID")
+		return
+	}
+
+	if !h.authorizeMethod(w, r) {
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		h.getFileByID(w, r, id)
+	case action == "" && r.Method == http.MethodPut:
+		h.renameFile(w, r, id)
+	case action == "" && r.Method == http.MethodDelete:
+		h.deleteFile(w, r, id)
+	case action == "move" && r.Method == http.MethodPut:
+		h.moveFile(w, r, id)
+	case action == "content" && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+		h.downloadFile(w, r, id)
+	case action == "thumbnail" && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+		h.downloadThumbnail(w, r, id)
+	case action == "status" && r.Method == http.MethodGet:
+		h.getFileStatus(w, r, id)
+	case action != "" && action != "move" && action != "content" && action != "thumbnail" && action != "status":
+		h.respondWithError(w, http.StatusNotFound, "not found")
+	default:
+		h.respondWithError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// authorizeMethod requires the read permission for safe methods and the write permission for everything else
+func (h *Handler) authorizeMethod(w http.ResponseWriter, r *http.Request) bool {
+	permission := userEntity.PermissionWrite
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		permission = userEntity.PermissionRead
+	}
+
+	if !auth.Authorize(r.Context(), permission) {
+		h.respondWithError(w, http.StatusForbidden, "forbidden")
+		return false
+	}
+
+	return true
+}
+
+// parseFilePath splits /api/files/{id}[/{action}] into its ID and optional action
+func parseFilePath(p string) (int64, string, error) {
+	segments := strings.SplitN(strings.Trim(strings.TrimPrefix(p, basePath), "/"), "/", 2)
+
+	id, err := strconv.ParseInt(segments[0], 10, 64)
+	if err != nil {
+		return 0, "", err
+	}
+
+	if len(segments) == 2 {
+		return id, segments[1], nil
+	}
+	return id, "", nil
+}
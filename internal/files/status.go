@@ -0,0 +1,49 @@
+package files
+
+import (
+	"net/http"
+
+	jobEntity "github.com/yansilvacerqueira/api-files/internal/jobs/entity"
+)
+
+// statusUnknown is reported for files uploaded before jobs were tracked
+const statusUnknown = "unknown"
+
+// getFileStatus reports whether the worker has processed a file. The status is the one of the
+// newest compression job, since that is what downloads depend on; every job is listed with its
+// attempts and last error so clients can poll.
+func (h *Handler) getFileStatus(w http.ResponseWriter, r *http.Request, id int64) {
+	ctx := r.Context()
+	file, err := h.getAccessibleFile(ctx, id)
+	if err != nil {
+		h.respondWithRepoError(w, err, "failed to fetch file status")
+		return
+	}
+
+	jobs, err := h.jobs.GetJobsByFile(ctx, id)
+	if err != nil {
+		h.logger.Printf("Error fetching jobs of file %d: %v", id, err)
+		h.respondWithError(w, http.StatusInternalServerError, "failed to fetch file status")
+		return
+	}
+
+	status := statusUnknown
+	sanitizedJobs := make([]map[string]interface{}, 0, len(jobs))
+	for _, job := range jobs {
+		// Jobs are sorted newest first
+		if job.Type == jobEntity.TypeCompress && status == statusUnknown {
+			status = string(job.Status)
+		}
+		sanitizedJobs = append(sanitizedJobs, job.Sanitize())
+	}
+
+	h.respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"file_id":    file.ID,
+		"status":     status,
+		"processed":  status == string(jobEntity.StatusSucceeded),
+		"compressed": file.IsCompressed(),
+		"codec":      file.Codec,
+		"checksum":   file.Checksum,
+		"jobs":       sanitizedJobs,
+	})
+}
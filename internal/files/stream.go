@@ -0,0 +1,55 @@
+package files
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+// The server timeouts are sized for JSON requests, so uploads and downloads move the connection
+// deadlines forward while data flows instead. A transfer is only cut off once it stalls for the
+// stream timeout, however large the file is.
+
+// streamWriter wraps w so every write extends the write deadline by the stream timeout
+func (h *Handler) streamWriter(w http.ResponseWriter) io.Writer {
+	return &deadlineWriter{
+		w:          w,
+		controller: http.NewResponseController(w),
+		timeout:    h.streamTimeout,
+	}
+}
+
+// streamBody wraps a request body so every read extends the read deadline by the stream timeout.
+// The write deadline is extended along with it, so a long upload can still be answered.
+func (h *Handler) streamBody(w http.ResponseWriter, body io.ReadCloser) io.ReadCloser {
+	return &deadlineReader{
+		ReadCloser: body,
+		controller: http.NewResponseController(w),
+		timeout:    h.streamTimeout,
+	}
+}
+
+type deadlineWriter struct {
+	w          io.Writer
+	controller *http.ResponseController
+	timeout    time.Duration
+}
+
+func (dw *deadlineWriter) Write(p []byte) (int, error) {
+	// Writers without deadlines, such as test recorders, report ErrNotSupported and need none
+	_ = dw.controller.SetWriteDeadline(time.Now().Add(dw.timeout))
+	return dw.w.Write(p)
+}
+
+type deadlineReader struct {
+	io.ReadCloser
+	controller *http.ResponseController
+	timeout    time.Duration
+}
+
+func (dr *deadlineReader) Read(p []byte) (int, error) {
+	deadline := time.Now().Add(dr.timeout)
+	_ = dr.controller.SetReadDeadline(deadline)
+	_ = dr.controller.SetWriteDeadline(deadline)
+	return dr.ReadCloser.Read(p)
+}
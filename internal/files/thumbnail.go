@@ -0,0 +1,56 @@
+package files
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/yansilvacerqueira/api-files/internal/bucket"
+	"github.com/yansilvacerqueira/api-files/internal/files/entity"
+)
+
+// downloadThumbnail serves the JPEG preview the worker renders for image uploads. Files that are
+// not images, or whose thumbnail has not been rendered yet, answer 404.
+func (h *Handler) downloadThumbnail(w http.ResponseWriter, r *http.Request, id int64) {
+	file, err := h.getAccessibleFile(r.Context(), id)
+	if err != nil {
+		h.respondWithRepoError(w, err, "failed to fetch file")
+		return
+	}
+
+	// Thumbnails are only written once per upload, so the file identity is enough for the ETag
+	etag := fmt.Sprintf(`"%d-%d-thumbnail"`, file.ID, file.CreatedAt.UnixNano())
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	object, err := h.compact.Open(entity.ThumbnailKey(file.Path))
+	if err != nil {
+		if errors.Is(err, bucket.ErrObjectNotFound) {
+			h.respondWithError(w, http.StatusNotFound, "thumbnail not found")
+			return
+		}
+
+		h.logger.Printf("Error opening thumbnail of file %d: %v", file.ID, err)
+		h.respondWithError(w, http.StatusBadGateway, "failed to download thumbnail")
+		return
+	}
+	defer object.Body.Close()
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Content-Length", strconv.FormatInt(object.Size, 10))
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	if _, err := io.Copy(h.streamWriter(w), object.Body); err != nil {
+		h.logger.Printf("Error streaming thumbnail of file %d: %v", file.ID, err)
+	}
+}
@@ -0,0 +1,209 @@
+package files
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/yansilvacerqueira/api-files/internal/auth"
+	"github.com/yansilvacerqueira/api-files/internal/bucket"
+	"github.com/yansilvacerqueira/api-files/internal/files/entity"
+	"github.com/yansilvacerqueira/api-files/internal/files/repository"
+	jobEntity "github.com/yansilvacerqueira/api-files/internal/jobs/entity"
+)
+
+const (
+	uploadPrefix       = "uploads"
+	defaultContentType = "application/octet-stream"
+)
+
+var errMissingFilePart = errors.New("multipart body must contain a file part")
+
+// uploadFile streams a multipart body into the bucket, stores the files row and enqueues its compression.
+// An optional folder_id field is honored when it is sent before the file part.
+func (h *Handler) uploadFile(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, h.streamBody(w, r.Body), h.maxUploadSize)
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "request must be multipart/form-data")
+		return
+	}
+
+	ctx := r.Context()
+	var folderID *int64
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			h.respondWithError(w, http.StatusBadRequest, errMissingFilePart.Error())
+			return
+		}
+		if err != nil {
+			h.respondWithError(w, http.StatusBadRequest, "invalid multipart body")
+			return
+		}
+
+		switch part.FormName() {
+		case "folder_id":
+			id, err := readFolderID(part)
+			part.Close()
+			if err != nil {
+				h.respondWithError(w, http.StatusBadRequest, "invalid folder ID")
+				return
+			}
+			if !h.folderExists(w, r, id) {
+				return
+			}
+			folderID = &id
+
+		case "file":
+			h.storeUpload(ctx, w, part, folderID)
+			part.Close()
+			return
+
+		default:
+			part.Close()
+		}
+	}
+}
+
+func (h *Handler) storeUpload(ctx context.Context, w http.ResponseWriter, part *multipart.Part, folderID *int64) {
+	// The name becomes the last segment of the object key, so it is validated before the key is built
+	name := strings.TrimSpace(part.FileName())
+	if err := entity.ValidateName(name); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	key, err := newObjectKey(name)
+	if err != nil {
+		h.logger.Printf("Error generating object key: %v", err)
+		h.respondWithError(w, http.StatusInternalServerError, "failed to upload file")
+		return
+	}
+
+	var ownerID *int64
+	if userID, ok := auth.UserIDFromContext(ctx); ok {
+		ownerID = &userID
+	}
+
+	file, err := entity.NewFile(ownerID, folderID, name, detectContentType(part, name), key)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Providers may not wrap the error of the body they read, so it is recorded on the way in
+	body := &recordingReader{r: part}
+	if err := h.raw.UploadWithOptions(body, key, bucket.UploadOptions{ContentType: file.Type}); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) || errors.As(body.err, &maxBytesErr) {
+			h.respondWithError(w, http.StatusRequestEntityTooLarge, "file is too large")
+			return
+		}
+
+		h.logger.Printf("Error uploading file %q: %v", key, err)
+		h.respondWithError(w, http.StatusInternalServerError, "failed to upload file")
+		return
+	}
+
+	if err := h.repo.CreateFile(ctx, file); err != nil {
+		h.removeObject(key)
+		// The folder may have been deleted while the body was streamed
+		if errors.Is(err, repository.ErrFolderNotFound) {
+			h.respondWithError(w, http.StatusNotFound, "folder not found")
+			return
+		}
+
+		h.logger.Printf("Error creating file row for %q: %v", key, err)
+		h.respondWithError(w, http.StatusInternalServerError, "failed to upload file")
+		return
+	}
+
+	// Without compression the upload is incomplete, so failing to queue it rolls the upload back
+	if err := h.enqueueUploadJob(ctx, file, jobEntity.TypeCompress); err != nil {
+		h.logger.Printf("Error enqueueing compression of file %d: %v", file.ID, err)
+		h.rollbackFile(ctx, file)
+		h.respondWithError(w, http.StatusInternalServerError, "failed to upload file")
+		return
+	}
+
+	// The other jobs only add metadata and are not worth failing the upload for
+	for _, jobType := range followUpJobTypes(file) {
+		if _, err := h.enqueueJob(ctx, file, jobType); err != nil {
+			h.logger.Printf("Error enqueueing %s job of file %d: %v", jobType, file.ID, err)
+		}
+	}
+
+	h.respondWithJSON(w, http.StatusCreated, file.Sanitize())
+}
+
+// rollbackFile removes the row and object of an upload that could not be queued for processing
+func (h *Handler) rollbackFile(ctx context.Context, file *entity.File) {
+	if err := h.repo.DeleteFile(ctx, file.ID); err != nil {
+		h.logger.Printf("Error rolling back file %d: %v", file.ID, err)
+	}
+	h.removeObject(file.Path)
+}
+
+func (h *Handler) removeObject(key string) {
+	if err := h.raw.Delete(key); err != nil {
+		h.logger.Printf("Error removing orphaned object %q: %v", key, err)
+	}
+}
+
+// recordingReader keeps the first error other than io.EOF returned by r
+type recordingReader struct {
+	r   io.Reader
+	err error
+}
+
+func (rr *recordingReader) Read(p []byte) (int, error) {
+	n, err := rr.r.Read(p)
+	if err != nil && err != io.EOF && rr.err == nil {
+		rr.err = err
+	}
+	return n, err
+}
+
+// newObjectKey places every upload under its own random directory so names never collide
+func newObjectKey(name string) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return path.Join(uploadPrefix, hex.EncodeToString(buf), name), nil
+}
+
+// detectContentType trusts the part header unless it is missing or generic, then falls back to the extension
+func detectContentType(part *multipart.Part, name string) string {
+	if mediaType, _, err := mime.ParseMediaType(part.Header.Get("Content-Type")); err == nil && mediaType != defaultContentType {
+		return mediaType
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(mime.TypeByExtension(filepath.Ext(name))); err == nil {
+		return mediaType
+	}
+
+	return defaultContentType
+}
+
+func readFolderID(part *multipart.Part) (int64, error) {
+	value, err := io.ReadAll(io.LimitReader(part, 32))
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(strings.TrimSpace(string(value)), 10, 64)
+}
@@ -0,0 +1,91 @@
+package entity
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+const maxNameLength = 60
+
+var (
+	ErrEmptyName     = errors.New("folder name is required")
+	ErrNameTooLong   = errors.New("folder name must be at most 60 characters long")
+	ErrInvalidName   = errors.New("folder name must not contain slashes")
+	ErrCycleDetected = errors.New("folder cannot be moved into itself or one of its descendants")
+)
+
+type Folder struct {
+	ID        int64
+	ParentID  *int64
+	Name      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Deleted   bool
+}
+
+func NewFolder(name string, parentID *int64) (*Folder, error) {
+	now := time.Now()
+
+	name = strings.TrimSpace(name)
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+
+	return &Folder{
+		ParentID:  parentID,
+		Name:      name,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}, nil
+}
+
+func (f *Folder) Rename(name string) error {
+	name = strings.TrimSpace(name)
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+
+	f.Name = name
+	f.UpdatedAt = time.Now()
+	return nil
+}
+
+// MoveTo sets the folder parent; a nil parentID moves the folder to the root.
+// Descendant checks need the repository and are done by the caller.
+func (f *Folder) MoveTo(parentID *int64) error {
+	if parentID != nil && *parentID == f.ID {
+		return ErrCycleDetected
+	}
+
+	f.ParentID = parentID
+	f.UpdatedAt = time.Now()
+	return nil
+}
+
+func (f *Folder) IsDeleted() bool {
+	return f.Deleted
+}
+
+func ValidateName(name string) error {
+	if name == "" {
+		return ErrEmptyName
+	}
+	if len([]rune(name)) > maxNameLength {
+		return ErrNameTooLong
+	}
+	if strings.ContainsAny(name, `/\`) {
+		return ErrInvalidName
+	}
+	return nil
+}
+
+func (f *Folder) Sanitize() map[string]interface{} {
+	return map[string]interface{}{
+		"id":         f.ID,
+		"parent_id":  f.ParentID,
+		"name":       f.Name,
+		"created_at": f.CreatedAt,
+		"updated_at": f.UpdatedAt,
+	}
+}
@@ -0,0 +1,211 @@
+package folders
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/yansilvacerqueira/api-files/internal/auth"
+	"github.com/yansilvacerqueira/api-files/internal/folders/entity"
+	"github.com/yansilvacerqueira/api-files/internal/folders/repository"
+)
+
+type Handler struct {
+	db     *sql.DB
+	logger *log.Logger
+	repo   *repository.FolderRepository
+	auth   *auth.Handler
+}
+
+type Config struct {
+	DB     *sql.DB
+	Logger *log.Logger
+	Auth   *auth.Handler
+}
+
+type createFolderRequest struct {
+	Name     string `json:"name"`
+	ParentID *int64 `json:"parent_id,omitempty"`
+}
+
+type renameFolderRequest struct {
+	Name string `json:"name"`
+}
+
+// moveFolderRequest moves a folder under ParentID, or to the root when ParentID is null
+type moveFolderRequest struct {
+	ParentID *int64 `json:"parent_id"`
+}
+
+func NewHandler(cfg Config) (*Handler, error) {
+	if cfg.DB == nil {
+		return nil, errors.New("database connection is required")
+	}
+	if cfg.Auth == nil {
+		return nil, errors.New("auth handler is required")
+	}
+
+	logger := cfg.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	repo := repository.NewFolderRepository(cfg.DB)
+
+	return &Handler{
+		db:     cfg.DB,
+		logger: logger,
+		repo:   repo,
+		auth:   cfg.Auth,
+	}, nil
+}
+
+func (h *Handler) listRootFolders(w http.ResponseWriter, r *http.Request) {
+	h.listChildren(w, r, nil)
+}
+
+func (h *Handler) listChildren(w http.ResponseWriter, r *http.Request, parentID *int64) {
+	ctx := r.Context()
+
+	if parentID != nil {
+		if _, err := h.repo.GetFolderByID(ctx, *parentID); err != nil {
+			h.respondWithRepoError(w, err, "failed to fetch folder")
+			return
+		}
+	}
+
+	folders, err := h.repo.GetChildren(ctx, parentID)
+	if err != nil {
+		h.logger.Printf("Error fetching folders: %v", err)
+		h.respondWithError(w, http.StatusInternalServerError, "failed to fetch folders")
+		return
+	}
+
+	sanitizedFolders := make([]map[string]interface{}, 0, len(folders))
+	for _, folder := range folders {
+		sanitizedFolders = append(sanitizedFolders, folder.Sanitize())
+	}
+
+	h.respondWithJSON(w, http.StatusOK, sanitizedFolders)
+}
+
+func (h *Handler) getFolderByID(w http.ResponseWriter, r *http.Request, id int64) {
+	folder, err := h.repo.GetFolderByID(r.Context(), id)
+	if err != nil {
+		h.respondWithRepoError(w, err, "failed to fetch folder")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, folder.Sanitize())
+}
+
+func (h *Handler) createFolder(w http.ResponseWriter, r *http.Request) {
+	var req createFolderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	folder, err := entity.NewFolder(req.Name, req.ParentID)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// The repository checks the parent while holding its lock
+	if err := h.repo.CreateFolder(r.Context(), folder); err != nil {
+		h.respondWithRepoError(w, err, "failed to create folder")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusCreated, folder.Sanitize())
+}
+
+func (h *Handler) renameFolder(w http.ResponseWriter, r *http.Request, id int64) {
+	var req renameFolderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	ctx := r.Context()
+	folder, err := h.repo.GetFolderByID(ctx, id)
+	if err != nil {
+		h.respondWithRepoError(w, err, "failed to rename folder")
+		return
+	}
+
+	if err := folder.Rename(req.Name); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.repo.RenameFolder(ctx, folder); err != nil {
+		h.logger.Printf("Error renaming folder %d: %v", id, err)
+		h.respondWithError(w, http.StatusInternalServerError, "failed to rename folder")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, folder.Sanitize())
+}
+
+func (h *Handler) moveFolder(w http.ResponseWriter, r *http.Request, id int64) {
+	var req moveFolderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	ctx := r.Context()
+	folder, err := h.repo.GetFolderByID(ctx, id)
+	if err != nil {
+		h.respondWithRepoError(w, err, "failed to move folder")
+		return
+	}
+
+	if err := folder.MoveTo(req.ParentID); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// The repository checks the parent and the descendants while holding the move lock
+	if err := h.repo.MoveFolder(ctx, folder); err != nil {
+		if errors.Is(err, entity.ErrCycleDetected) {
+			h.respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		h.respondWithRepoError(w, err, "failed to move folder")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, folder.Sanitize())
+}
+
+// deleteFolder only deletes empty folders, so nothing is left behind under a deleted parent
+func (h *Handler) deleteFolder(w http.ResponseWriter, r *http.Request, id int64) {
+	if err := h.repo.DeleteFolder(r.Context(), id); err != nil {
+		if errors.Is(err, repository.ErrFolderNotEmpty) {
+			h.respondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
+
+		h.respondWithRepoError(w, err, "failed to delete folder")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, map[string]string{"message": "folder deleted successfully"})
+}
+
+// respondWithRepoError maps ErrFolderNotFound to 404 and anything else to 500 with the given message
+func (h *Handler) respondWithRepoError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, repository.ErrFolderNotFound) {
+		h.respondWithError(w, http.StatusNotFound, "folder not found")
+		return
+	}
+
+	h.logger.Printf("Error: %s: %v", message, err)
+	h.respondWithError(w, http.StatusInternalServerError, message)
+}
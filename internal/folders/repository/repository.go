@@ -0,0 +1,293 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/yansilvacerqueira/api-files/internal/folders/entity"
+)
+
+var (
+	ErrFolderNotFound = errors.New("folder not found")
+	ErrFolderNotEmpty = errors.New("folder is not empty")
+)
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+type FolderRepository struct {
+	db *sql.DB
+}
+
+func NewFolderRepository(db *sql.DB) *FolderRepository {
+	return &FolderRepository{db: db}
+}
+
+func scanFolder(s scanner) (*entity.Folder, error) {
+	folder := &entity.Folder{}
+	var parentID sql.NullInt64
+
+	err := s.Scan(
+		&folder.ID,
+		&parentID,
+		&folder.Name,
+		&folder.CreatedAt,
+		&folder.UpdatedAt,
+		&folder.Deleted,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if parentID.Valid {
+		folder.ParentID = &parentID.Int64
+	}
+
+	return folder, nil
+}
+
+func (r *FolderRepository) GetFolderByID(ctx context.Context, id int64) (*entity.Folder, error) {
+	query := `
+		SELECT id, parent_id, name, created_at, updated_at, deleted
+		FROM folders
+		WHERE id = $1 AND deleted = false
+	`
+
+	folder, err := scanFolder(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrFolderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return folder, nil
+}
+
+// GetChildren lists the folders directly under parentID; a nil parentID lists the root folders.
+func (r *FolderRepository) GetChildren(ctx context.Context, parentID *int64) ([]entity.Folder, error) {
+	query := `
+		SELECT id, parent_id, name, created_at, updated_at, deleted
+		FROM folders
+		WHERE parent_id IS NOT DISTINCT FROM $1 AND deleted = false
+		ORDER BY name ASC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, nullableID(parentID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var folders []entity.Folder
+	for rows.Next() {
+		folder, err := scanFolder(rows)
+		if err != nil {
+			return nil, err
+		}
+		folders = append(folders, *folder)
+	}
+
+	return folders, rows.Err()
+}
+
+// CreateFolder inserts the folder. Its parent is locked first, so a concurrent DeleteFolder either
+// sees the new folder or has already deleted the parent, which is then reported as ErrFolderNotFound.
+func (r *FolderRepository) CreateFolder(ctx context.Context, folder *entity.Folder) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if folder.ParentID != nil {
+		if err := lockParent(ctx, tx, *folder.ParentID); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		INSERT INTO folders (parent_id, name, created_at, updated_at, deleted)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+
+	err = tx.QueryRowContext(ctx, query,
+		nullableID(folder.ParentID),
+		folder.Name,
+		folder.CreatedAt,
+		folder.UpdatedAt,
+		folder.Deleted,
+	).Scan(&folder.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RenameFolder stores the folder name; the parent is only ever changed by MoveFolder
+func (r *FolderRepository) RenameFolder(ctx context.Context, folder *entity.Folder) error {
+	query := `
+		UPDATE folders
+		SET name = $1, updated_at = $2
+		WHERE id = $3 AND deleted = false
+	`
+
+	result, err := r.db.ExecContext(ctx, query,
+		folder.Name,
+		folder.UpdatedAt,
+		folder.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrFolderNotFound
+	}
+
+	return nil
+}
+
+// MoveFolder stores the folder parent. Moves are serialized by a transaction-level advisory lock,
+// so the descendant check cannot be invalidated by a concurrent move before the update commits,
+// and the new parent is locked like in CreateFolder. It returns entity.ErrCycleDetected when the
+// parent sits below the folder.
+func (r *FolderRepository) MoveFolder(ctx context.Context, folder *entity.Folder) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('folder_moves'))`); err != nil {
+		return err
+	}
+
+	if folder.ParentID != nil {
+		if err := lockParent(ctx, tx, *folder.ParentID); err != nil {
+			return err
+		}
+
+		isDescendant, err := isDescendant(ctx, tx, folder.ID, *folder.ParentID)
+		if err != nil {
+			return err
+		}
+		if isDescendant {
+			return entity.ErrCycleDetected
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE folders
+		SET parent_id = $1, updated_at = $2
+		WHERE id = $3 AND deleted = false
+	`, nullableID(folder.ParentID), folder.UpdatedAt, folder.ID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrFolderNotFound
+	}
+
+	return tx.Commit()
+}
+
+// DeleteFolder soft-deletes a folder that holds no live folders or files. The row is locked first,
+// and CreateFolder, MoveFolder and the files repository lock the folder they put something into
+// the same way, so nothing lands in the folder between the emptiness check and the delete.
+func (r *FolderRepository) DeleteFolder(ctx context.Context, id int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var locked int64
+	err = tx.QueryRowContext(ctx, `
+		SELECT id FROM folders WHERE id = $1 AND deleted = false FOR UPDATE
+	`, id).Scan(&locked)
+	if err == sql.ErrNoRows {
+		return ErrFolderNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	var notEmpty bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM folders WHERE parent_id = $1 AND deleted = false)
+			OR EXISTS (SELECT 1 FROM files WHERE folder_id = $1 AND deleted = false)
+	`, id).Scan(&notEmpty)
+	if err != nil {
+		return err
+	}
+	if notEmpty {
+		return ErrFolderNotEmpty
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE folders
+		SET deleted = true, updated_at = NOW()
+		WHERE id = $1
+	`, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// lockParent takes a share lock on a live folder that something is about to be put into. It waits
+// for a DeleteFolder holding the row and returns ErrFolderNotFound if that deleted it.
+func lockParent(ctx context.Context, tx *sql.Tx, id int64) error {
+	var locked int64
+	err := tx.QueryRowContext(ctx, `
+		SELECT id FROM folders WHERE id = $1 AND deleted = false FOR SHARE
+	`, id).Scan(&locked)
+	if err == sql.ErrNoRows {
+		return ErrFolderNotFound
+	}
+
+	return err
+}
+
+// isDescendant reports whether candidateID sits anywhere below ancestorID in the folder tree.
+func isDescendant(ctx context.Context, tx *sql.Tx, ancestorID, candidateID int64) (bool, error) {
+	query := `
+		WITH RECURSIVE descendants AS (
+			SELECT id FROM folders WHERE parent_id = $1 AND deleted = false
+			UNION
+			SELECT f.id FROM folders f
+			INNER JOIN descendants d ON f.parent_id = d.id
+			WHERE f.deleted = false
+		)
+		SELECT EXISTS (SELECT 1 FROM descendants WHERE id = $2)
+	`
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, query, ancestorID, candidateID).Scan(&exists); err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+func nullableID(id *int64) sql.NullInt64 {
+	if id == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: *id, Valid: true}
+}
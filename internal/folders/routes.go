@@ -0,0 +1,92 @@
+package folders
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/yansilvacerqueira/api-files/internal/auth"
+	userEntity "github.com/yansilvacerqueira/api-files/internal/users/entity"
+)
+
+const basePath = "/api/folders/"
+
+func (h *Handler) SetRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/api/folders", h.auth.RequireAuth(h.handleFolders))
+	mux.HandleFunc(basePath, h.auth.RequireAuth(h.handleFolderByID))
+}
+
+func (h *Handler) handleFolders(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeMethod(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.listRootFolders(w, r)
+	case http.MethodPost:
+		h.createFolder(w, r)
+	default:
+		h.respondWithError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleFolderByID serves /api/folders/{id}, /api/folders/{id}/children and /api/folders/{id}/move
+func (h *Handler) handleFolderByID(w http.ResponseWriter, r *http.Request) {
+	id, action, err := parseFolderPath(r.URL.Path)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "invalid folder ID")
+		return
+	}
+
+	if !h.authorizeMethod(w, r) {
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		h.getFolderByID(w, r, id)
+	case action == "" && r.Method == http.MethodPut:
+		h.renameFolder(w, r, id)
+	case action == "" && r.Method == http.MethodDelete:
+		h.deleteFolder(w, r, id)
+	case action == "children" && r.Method == http.MethodGet:
+		h.listChildren(w, r, &id)
+	case action == "move" && r.Method == http.MethodPut:
+		h.moveFolder(w, r, id)
+	case action != "" && action != "children" && action != "move":
+		h.respondWithError(w, http.StatusNotFound, "not found")
+	default:
+		h.respondWithError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// authorizeMethod requires the read permission for safe methods and the write permission for everything else
+func (h *Handler) authorizeMethod(w http.ResponseWriter, r *http.Request) bool {
+	permission := userEntity.PermissionWrite
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		permission = userEntity.PermissionRead
+	}
+
+	if !auth.Authorize(r.Context(), permission) {
+		h.respondWithError(w, http.StatusForbidden, "forbidden")
+		return false
+	}
+
+	return true
+}
+
+// parseFolderPath splits /api/folders/{id}[/{action}] into its ID and optional action
+func parseFolderPath(p string) (int64, string, error) {
+	segments := strings.SplitN(strings.Trim(strings.TrimPrefix(p, basePath), "/"), "/", 2)
+
+	id, err := strconv.ParseInt(segments[0], 10, 64)
+	if err != nil {
+		return 0, "", err
+	}
+
+	if len(segments) == 2 {
+		return id, segments[1], nil
+	}
+	return id, "", nil
+}
@@ -0,0 +1,92 @@
+package entity
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+const maxTypeLength = 30
+
+// Status is where a job is in its lifecycle
+type Status string
+
+const (
+	// StatusQueued jobs wait in the queue, either for their first attempt or for a retry
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	// StatusFailed jobs failed permanently or ran out of retries
+	StatusFailed Status = "failed"
+)
+
+// Job types, matching the queue message types the worker dispatches on
+const (
+	// TypeCompress compresses a raw upload into the compact bucket
+	TypeCompress = "compress"
+	// TypeThumbnail renders a preview of an image upload
+	TypeThumbnail = "thumbnail"
+	// TypeChecksum records the SHA-256 of a raw upload
+	TypeChecksum = "checksum"
+	// TypeCleanup removes the stored objects of a deleted file
+	TypeCleanup = "cleanup"
+)
+
+var (
+	ErrEmptyType   = errors.New("job type is required")
+	ErrTypeTooLong = errors.New("job type must be at most 30 characters long")
+)
+
+// Job records the processing of a file by the worker
+type Job struct {
+	ID         int64
+	FileID     int64
+	Type       string
+	Status     Status
+	Attempts   int
+	Error      string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	StartedAt  *time.Time
+	FinishedAt *time.Time
+}
+
+func NewJob(fileID int64, jobType string) (*Job, error) {
+	now := time.Now()
+
+	jobType = strings.TrimSpace(jobType)
+	if jobType == "" {
+		return nil, ErrEmptyType
+	}
+	if len(jobType) > maxTypeLength {
+		return nil, ErrTypeTooLong
+	}
+
+	return &Job{
+		FileID:    fileID,
+		Type:      jobType,
+		Status:    StatusQueued,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}, nil
+}
+
+// IsFinished reports whether the job reached a final status
+func (j *Job) IsFinished() bool {
+	return j.Status == StatusSucceeded || j.Status == StatusFailed
+}
+
+func (j *Job) Sanitize() map[string]interface{} {
+	return map[string]interface{}{
+		"id":          j.ID,
+		"file_id":     j.FileID,
+		"type":        j.Type,
+		"status":      j.Status,
+		"attempts":    j.Attempts,
+		"error":       j.Error,
+		"created_at":  j.CreatedAt,
+		"updated_at":  j.UpdatedAt,
+		"started_at":  j.StartedAt,
+		"finished_at": j.FinishedAt,
+	}
+}
@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/yansilvacerqueira/api-files/internal/jobs/entity"
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+)
+
+const jobColumns = `id, file_id, type, status, attempts, error, created_at, updated_at, started_at, finished_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+type JobRepository struct {
+	db *sql.DB
+}
+
+func NewJobRepository(db *sql.DB) *JobRepository {
+	return &JobRepository{db: db}
+}
+
+func scanJob(s scanner) (*entity.Job, error) {
+	job := &entity.Job{}
+	var jobError sql.NullString
+	var startedAt, finishedAt sql.NullTime
+
+	err := s.Scan(
+		&job.ID,
+		&job.FileID,
+		&job.Type,
+		&job.Status,
+		&job.Attempts,
+		&jobError,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+		&startedAt,
+		&finishedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	job.Error = jobError.String
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+
+	return job, nil
+}
+
+func (r *JobRepository) CreateJob(ctx context.Context, job *entity.Job) error {
+	query := `
+		INSERT INTO processing_jobs (file_id, type, status, attempts, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`
+
+	return r.db.QueryRowContext(ctx, query,
+		job.FileID,
+		job.Type,
+		job.Status,
+		job.Attempts,
+		job.CreatedAt,
+		job.UpdatedAt,
+	).Scan(&job.ID)
+}
+
+func (r *JobRepository) GetJobByID(ctx context.Context, id int64) (*entity.Job, error) {
+	query := `SELECT ` + jobColumns + ` FROM processing_jobs WHERE id = $1`
+
+	job, err := scanJob(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// GetJobsByFile lists the jobs of a file, newest first
+func (r *JobRepository) GetJobsByFile(ctx context.Context, fileID int64) ([]entity.Job, error) {
+	query := `SELECT ` + jobColumns + ` FROM processing_jobs WHERE file_id = $1 ORDER BY created_at DESC, id DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, fileID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []entity.Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *job)
+	}
+
+	return jobs, rows.Err()
+}
+
+// MarkRunning starts a new attempt of the job
+func (r *JobRepository) MarkRunning(ctx context.Context, id int64) error {
+	query := `
+		UPDATE processing_jobs
+		SET status = $1, attempts = attempts + 1, started_at = NOW(), finished_at = NULL, updated_at = NOW()
+		WHERE id = $2
+	`
+
+	return r.exec(ctx, query, entity.StatusRunning, id)
+}
+
+func (r *JobRepository) MarkSucceeded(ctx context.Context, id int64) error {
+	query := `
+		UPDATE processing_jobs
+		SET status = $1, error = NULL, finished_at = NOW(), updated_at = NOW()
+		WHERE id = $2
+	`
+
+	return r.exec(ctx, query, entity.StatusSucceeded, id)
+}
+
+// MarkRetrying puts a failed attempt back to queued while the queue schedules another one
+func (r *JobRepository) MarkRetrying(ctx context.Context, id int64, reason string) error {
+	query := `
+		UPDATE processing_jobs
+		SET status = $1, error = $2, updated_at = NOW()
+		WHERE id = $3
+	`
+
+	return r.exec(ctx, query, entity.StatusQueued, reason, id)
+}
+
+// MarkFailed records the final failure of the job
+func (r *JobRepository) MarkFailed(ctx context.Context, id int64, reason string) error {
+	query := `
+		UPDATE processing_jobs
+		SET status = $1, error = $2, finished_at = NOW(), updated_at = NOW()
+		WHERE id = $3
+	`
+
+	return r.exec(ctx, query, entity.StatusFailed, reason, id)
+}
+
+// DeleteJob removes a job that never reached the queue, such as the one of a rolled back upload
+func (r *JobRepository) DeleteJob(ctx context.Context, id int64) error {
+	return r.exec(ctx, `DELETE FROM processing_jobs WHERE id = $1`, id)
+}
+
+// exec runs a single-row update, mapping a missing row to ErrJobNotFound
+func (r *JobRepository) exec(ctx context.Context, query string, args ...any) error {
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrJobNotFound
+	}
+
+	return nil
+}
+
+// CountJobsByStatus counts every job per status; statuses without jobs are left out
+func (r *JobRepository) CountJobsByStatus(ctx context.Context) (map[entity.Status]int, error) {
+	query := `
+		SELECT status, COUNT(*)
+		FROM processing_jobs
+		GROUP BY status
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[entity.Status]int)
+	for rows.Next() {
+		var status entity.Status
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		counts[status] = count
+	}
+
+	return counts, rows.Err()
+}
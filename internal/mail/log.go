@@ -0,0 +1,32 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// LogSender writes messages to w instead of delivering them, as a stand-in for local development
+type LogSender struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewLogSender(w io.Writer) *LogSender {
+	return &LogSender{w: w}
+}
+
+func (s *LogSender) Send(ctx context.Context, msg Message) error {
+	if msg.To == "" {
+		return ErrNoRecipient
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := fmt.Fprintf(s.w, "--- %s\nTo: %s\nSubject: %s\n\n%s\n\n",
+		time.Now().Format(time.RFC3339), msg.To, msg.Subject, msg.Body)
+	return err
+}
@@ -0,0 +1,20 @@
+package mail
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrNoRecipient = errors.New("message recipient is required")
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers email messages, allowing for flexibility in how mail leaves the system
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
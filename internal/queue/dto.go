@@ -1,11 +1,158 @@
 package queue
 
-import "encoding/json"
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
 
+	"github.com/yansilvacerqueira/api-files/internal/codec"
+)
+
+const (
+	// SchemaVersion is the envelope version written by NewQueueMessage
+	SchemaVersion = 1
+	// legacySchemaVersion marks messages decoded from the bare payload published before envelopes existed
+	legacySchemaVersion = 0
+)
+
+// Message types the worker dispatches on; all of them carry a FilePayload
+const (
+	MessageTypeCompress  = "compress"
+	MessageTypeThumbnail = "thumbnail"
+	MessageTypeChecksum  = "checksum"
+	MessageTypeCleanup   = "cleanup"
+)
+
+var (
+	ErrUnsupportedVersion = errors.New("unsupported message version")
+	ErrMissingType        = errors.New("message type is required")
+	ErrMissingCorrelation = errors.New("message correlation ID is required")
+	ErrMissingCreatedAt   = errors.New("message created_at is required")
+	ErrMissingPayload     = errors.New("message payload is required")
+)
+
+// QueueMessage is the envelope of every message: a schema version, the message type the worker
+// dispatches on, a correlation ID that ties logs of one upload together and the typed payload.
 type QueueMessage struct {
+	Version       int             `json:"version"`
+	Type          string          `json:"type"`
+	CorrelationID string          `json:"correlation_id"`
+	CreatedAt     time.Time       `json:"created_at"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// FilePayload locates the raw object of a file
+type FilePayload struct {
 	Filename string `json:"filename"`
 	Path     string `json:"path"`
 	ID       int    `json:"id"`
+	// JobID is the processing_jobs row tracking this message; zero for untracked messages
+	JobID int64 `json:"job_id,omitempty"`
+	// Codec and Level select the compression of the file; empty values fall back to the worker
+	// defaults. Level is a pointer since 0 is a valid level for gzip and brotli.
+	Codec string `json:"codec,omitempty"`
+	Level *int   `json:"level,omitempty"`
+}
+
+// Validate checks the fields every file job needs
+func (p *FilePayload) Validate() error {
+	if p.Filename == "" {
+		return errors.New("payload filename is required")
+	}
+	if p.Path == "" {
+		return errors.New("payload path is required")
+	}
+	if p.ID <= 0 {
+		return errors.New("payload id must be positive")
+	}
+	if p.Level != nil && *p.Level < 0 {
+		return errors.New("payload level must not be negative")
+	}
+	// The range of the worker default codec is only known to the worker, which checks it there
+	if p.Level != nil && p.Codec != "" {
+		if c, err := codec.Get(p.Codec); err == nil {
+			if err := codec.ValidateLevel(c, *p.Level); err != nil {
+				return fmt.Errorf("payload level: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// Key is the object key of the file in the bucket
+func (p *FilePayload) Key() string {
+	return p.Path + "/" + p.Filename
+}
+
+// NewQueueMessage wraps payload in an envelope of the current version with a fresh correlation ID
+func NewQueueMessage(messageType string, payload any) (*QueueMessage, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	correlationID, err := newCorrelationID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate correlation ID: %w", err)
+	}
+
+	message := &QueueMessage{
+		Version:       SchemaVersion,
+		Type:          messageType,
+		CorrelationID: correlationID,
+		CreatedAt:     time.Now().UTC(),
+		Payload:       body,
+	}
+
+	if err := message.Validate(); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// Validate checks the envelope fields required by its version
+func (m *QueueMessage) Validate() error {
+	if m.Version != SchemaVersion && m.Version != legacySchemaVersion {
+		return fmt.Errorf("%w: %d", ErrUnsupportedVersion, m.Version)
+	}
+	if m.Type == "" {
+		return ErrMissingType
+	}
+	if len(m.Payload) == 0 || bytes.Equal(m.Payload, []byte("null")) {
+		return ErrMissingPayload
+	}
+
+	// Legacy messages never carried envelope metadata
+	if m.Version == legacySchemaVersion {
+		return nil
+	}
+	if m.CorrelationID == "" {
+		return ErrMissingCorrelation
+	}
+	if m.CreatedAt.IsZero() {
+		return ErrMissingCreatedAt
+	}
+	return nil
+}
+
+// DecodePayload strictly decodes the payload into v, rejecting unknown fields, and validates it
+// when v has a Validate method
+func (m *QueueMessage) DecodePayload(v any) error {
+	if err := decodeStrict(m.Payload, v); err != nil {
+		return fmt.Errorf("invalid %s payload: %w", m.Type, err)
+	}
+
+	if validator, ok := v.(interface{ Validate() error }); ok {
+		if err := validator.Validate(); err != nil {
+			return fmt.Errorf("invalid %s payload: %w", m.Type, err)
+		}
+	}
+	return nil
 }
 
 // Marshal converts QueueMessage to JSON bytes
@@ -13,7 +160,70 @@ func (m *QueueMessage) ToJSON() ([]byte, error) {
 	return json.Marshal(m)
 }
 
-// Unmarshal parses JSON bytes into QueueMessage
+// Unmarshal parses JSON bytes into QueueMessage. Envelopes are decoded strictly and validated;
+// a body without a version is the legacy bare FilePayload and becomes a version 0 compress message.
 func (m *QueueMessage) FromJSON(data []byte) error {
-	return json.Unmarshal(data, m)
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	if _, ok := fields["version"]; !ok {
+		return m.fromLegacyJSON(data)
+	}
+
+	var message QueueMessage
+	if err := decodeStrict(data, &message); err != nil {
+		return err
+	}
+	if err := message.Validate(); err != nil {
+		return err
+	}
+
+	*m = message
+	return nil
+}
+
+func (m *QueueMessage) fromLegacyJSON(data []byte) error {
+	var payload FilePayload
+	if err := decodeStrict(data, &payload); err != nil {
+		return err
+	}
+	if err := payload.Validate(); err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	*m = QueueMessage{
+		Version: legacySchemaVersion,
+		Type:    MessageTypeCompress,
+		Payload: body,
+	}
+	return nil
+}
+
+// decodeStrict decodes exactly one JSON value into v, rejecting unknown fields and trailing data
+func decodeStrict(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if err := decoder.Decode(&json.RawMessage{}); err != io.EOF {
+		return errors.New("unexpected data after JSON value")
+	}
+	return nil
+}
+
+func newCorrelationID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
 }
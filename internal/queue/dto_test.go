@@ -0,0 +1,170 @@
+package queue
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/yansilvacerqueira/api-files/internal/codec"
+)
+
+func TestFromJSONVersion1(t *testing.T) {
+	data := []byte(`{
+		"version": 1,
+		"type": "compress",
+		"correlation_id": "9f86d081884c7d659a2feaa0c55ad015",
+		"created_at": "2024-01-01T12:00:00Z",
+		"payload": {"filename": "report.pdf", "path": "uploads/abc", "id": 42, "job_id": 7, "codec": "brotli", "level": 0}
+	}`)
+
+	var message QueueMessage
+	if err := message.FromJSON(data); err != nil {
+		t.Fatalf("FromJSON: %v", err)
+	}
+
+	if message.Version != SchemaVersion || message.Type != MessageTypeCompress {
+		t.Fatalf("got version %d type %q, want %d %q", message.Version, message.Type, SchemaVersion, MessageTypeCompress)
+	}
+	if message.CorrelationID != "9f86d081884c7d659a2feaa0c55ad015" {
+		t.Errorf("got correlation ID %q", message.CorrelationID)
+	}
+
+	var payload FilePayload
+	if err := message.DecodePayload(&payload); err != nil {
+		t.Fatalf("DecodePayload: %v", err)
+	}
+	if payload.Key() != "uploads/abc/report.pdf" || payload.ID != 42 || payload.JobID != 7 {
+		t.Errorf("got payload %+v", payload)
+	}
+	if payload.Level == nil || *payload.Level != 0 {
+		t.Errorf("got level %v, want an explicit 0", payload.Level)
+	}
+}
+
+func TestFromJSONLegacyPayload(t *testing.T) {
+	data := []byte(`{"filename": "report.pdf", "path": "uploads/abc", "id": 42}`)
+
+	var message QueueMessage
+	if err := message.FromJSON(data); err != nil {
+		t.Fatalf("FromJSON: %v", err)
+	}
+
+	if message.Version != legacySchemaVersion || message.Type != MessageTypeCompress {
+		t.Fatalf("got version %d type %q, want a version 0 compress message", message.Version, message.Type)
+	}
+
+	var payload FilePayload
+	if err := message.DecodePayload(&payload); err != nil {
+		t.Fatalf("DecodePayload: %v", err)
+	}
+	if payload.Key() != "uploads/abc/report.pdf" || payload.ID != 42 {
+		t.Errorf("got payload %+v", payload)
+	}
+	if payload.Level != nil {
+		t.Errorf("got level %d, want none", *payload.Level)
+	}
+}
+
+func TestFromJSONRejectsInvalidMessages(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want error
+	}{
+		{
+			name: "unknown envelope field",
+			data: `{"version": 1, "type": "compress", "correlation_id": "c", "created_at": "2024-01-01T12:00:00Z", "payload": {}, "priority": 1}`,
+		},
+		{
+			name: "unknown legacy field",
+			data: `{"filename": "report.pdf", "path": "uploads/abc", "id": 42, "size": 10}`,
+		},
+		{
+			name: "trailing data",
+			data: `{"version": 1, "type": "compress", "correlation_id": "c", "created_at": "2024-01-01T12:00:00Z", "payload": {}} {}`,
+		},
+		{
+			name: "unsupported version",
+			data: `{"version": 2, "type": "compress", "correlation_id": "c", "created_at": "2024-01-01T12:00:00Z", "payload": {}}`,
+			want: ErrUnsupportedVersion,
+		},
+		{
+			name: "missing type",
+			data: `{"version": 1, "correlation_id": "c", "created_at": "2024-01-01T12:00:00Z", "payload": {}}`,
+			want: ErrMissingType,
+		},
+		{
+			name: "missing correlation ID",
+			data: `{"version": 1, "type": "compress", "created_at": "2024-01-01T12:00:00Z", "payload": {}}`,
+			want: ErrMissingCorrelation,
+		},
+		{
+			name: "null payload",
+			data: `{"version": 1, "type": "compress", "correlation_id": "c", "created_at": "2024-01-01T12:00:00Z", "payload": null}`,
+			want: ErrMissingPayload,
+		},
+		{
+			name: "invalid legacy payload",
+			data: `{"filename": "report.pdf", "path": "uploads/abc", "id": 0}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var message QueueMessage
+			err := message.FromJSON([]byte(tt.data))
+			if err == nil {
+				t.Fatal("FromJSON succeeded, want an error")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("got error %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodePayloadRejectsNegativeLevel(t *testing.T) {
+	message := QueueMessage{Type: MessageTypeCompress, Payload: []byte(`{"filename": "a", "path": "b", "id": 1, "level": -1}`)}
+
+	var payload FilePayload
+	if err := message.DecodePayload(&payload); err == nil {
+		t.Fatal("DecodePayload accepted a negative level")
+	}
+}
+
+func TestDecodePayloadRejectsLevelsOutsideTheCodecRange(t *testing.T) {
+	payloads := []string{
+		`{"filename": "a", "path": "b", "id": 1, "codec": "gzip", "level": 42}`,
+		`{"filename": "a", "path": "b", "id": 1, "codec": "zstd", "level": 0}`,
+		`{"filename": "a", "path": "b", "id": 1, "codec": "brotli", "level": 12}`,
+	}
+
+	for _, body := range payloads {
+		message := QueueMessage{Type: MessageTypeCompress, Payload: []byte(body)}
+
+		var payload FilePayload
+		if err := message.DecodePayload(&payload); !errors.Is(err, codec.ErrInvalidLevel) {
+			t.Errorf("got error %v for %s, want codec.ErrInvalidLevel", err, body)
+		}
+	}
+}
+
+func TestQueueMessageRoundTrip(t *testing.T) {
+	level := 3
+	message, err := NewQueueMessage(MessageTypeChecksum, FilePayload{Filename: "a.txt", Path: "uploads/x", ID: 9, Level: &level})
+	if err != nil {
+		t.Fatalf("NewQueueMessage: %v", err)
+	}
+
+	data, err := message.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON: %v", err)
+	}
+
+	var decoded QueueMessage
+	if err := decoded.FromJSON(data); err != nil {
+		t.Fatalf("FromJSON: %v", err)
+	}
+	if decoded.CorrelationID != message.CorrelationID || decoded.Type != MessageTypeChecksum {
+		t.Errorf("got %+v, want %+v", decoded, message)
+	}
+}
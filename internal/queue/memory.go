@@ -0,0 +1,184 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// MemoryQueue is an in-memory QueueOperations implementation for hermetic tests.
+// Every published message is recorded and can be inspected with Published; messages that
+// exhaust their retries end up in DeadLetters. Retries are requeued without delay.
+type MemoryQueue struct {
+	mu          sync.Mutex
+	cond        *sync.Cond
+	published   [][]byte
+	pending     []memoryDelivery
+	deadLetters [][]byte
+	closed      bool
+	// MaxRetries is how many times a failed message is retried before it is dead-lettered, defaults to 5
+	MaxRetries int
+	// RetryPolicies overrides MaxRetries per message type; delays are ignored
+	RetryPolicies map[string]RetryPolicy
+}
+
+type memoryDelivery struct {
+	body    []byte
+	retries int
+}
+
+func NewMemoryQueue() *MemoryQueue {
+	mq := &MemoryQueue{}
+	mq.cond = sync.NewCond(&mq.mu)
+	return mq
+}
+
+// PublishMessage records the message and queues it for ReceiveMessage
+func (mq *MemoryQueue) PublishMessage(msg []byte) error {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+
+	if mq.closed {
+		return fmt.Errorf("queue is closed")
+	}
+
+	mq.published = append(mq.published, bytes.Clone(msg))
+	mq.pending = append(mq.pending, memoryDelivery{body: bytes.Clone(msg)})
+	mq.cond.Signal()
+
+	return nil
+}
+
+// ReceiveMessage passes queued messages to handler until ctx is cancelled, or until Close is
+// called and the queue is drained
+func (mq *MemoryQueue) ReceiveMessage(ctx context.Context, handler MessageHandler) error {
+	// Wakes next when ctx is cancelled while it waits for a message
+	stop := context.AfterFunc(ctx, func() {
+		mq.mu.Lock()
+		defer mq.mu.Unlock()
+
+		mq.cond.Broadcast()
+	})
+	defer stop()
+
+	for {
+		delivery, ok := mq.next(ctx)
+		if !ok {
+			return nil
+		}
+
+		var queueMessage QueueMessage
+
+		if err := queueMessage.FromJSON(delivery.body); err != nil {
+			log.Printf("failed to unmarshal message, dead-lettering it: %v", err)
+			mq.deadLetter(delivery)
+			continue
+		}
+
+		policy := resolveRetryPolicy(mq.RetryPolicies, queueMessage.Type, mq.MaxRetries, 0)
+		attempt := Attempt{Retries: delivery.retries, MaxRetries: policy.MaxRetries}
+		if err := handler(contextWithAttempt(context.WithoutCancel(ctx), attempt), queueMessage); err != nil {
+			mq.retry(delivery, attempt, err)
+		}
+	}
+}
+
+// next blocks until a message is pending, returning false once ctx is cancelled or the queue is closed and empty
+func (mq *MemoryQueue) next(ctx context.Context) (memoryDelivery, bool) {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+
+	for len(mq.pending) == 0 && !mq.closed && ctx.Err() == nil {
+		mq.cond.Wait()
+	}
+	if len(mq.pending) == 0 || ctx.Err() != nil {
+		return memoryDelivery{}, false
+	}
+
+	delivery := mq.pending[0]
+	mq.pending = mq.pending[1:]
+	return delivery, true
+}
+
+// retry requeues a failed delivery, or dead-letters it once err is permanent or retries run out
+func (mq *MemoryQueue) retry(delivery memoryDelivery, attempt Attempt, err error) {
+	if IsPermanent(err) || attempt.Last() {
+		log.Printf("message failed after %d retries, dead-lettering it: %v", delivery.retries, err)
+		mq.deadLetter(delivery)
+		return
+	}
+
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+
+	delivery.retries++
+	mq.pending = append(mq.pending, delivery)
+	mq.cond.Signal()
+}
+
+func (mq *MemoryQueue) deadLetter(delivery memoryDelivery) {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+
+	mq.deadLetters = append(mq.deadLetters, delivery.body)
+}
+
+// Close rejects further publishes and lets ReceiveMessage return once pending messages are delivered
+func (mq *MemoryQueue) Close() error {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+
+	mq.closed = true
+	mq.cond.Broadcast()
+	return nil
+}
+
+// State reports StateClosed once Close was called
+func (mq *MemoryQueue) State() ConnectionState {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+
+	if mq.closed {
+		return StateClosed
+	}
+	return StateConnected
+}
+
+// Published returns a copy of every message published so far, in order
+func (mq *MemoryQueue) Published() [][]byte {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+
+	published := make([][]byte, len(mq.published))
+	for i, body := range mq.published {
+		published[i] = bytes.Clone(body)
+	}
+	return published
+}
+
+// DeadLetters returns a copy of every message that was dead-lettered so far, in order
+func (mq *MemoryQueue) DeadLetters() [][]byte {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+
+	deadLetters := make([][]byte, len(mq.deadLetters))
+	for i, body := range mq.deadLetters {
+		deadLetters[i] = bytes.Clone(body)
+	}
+	return deadLetters
+}
+
+// PublishedMessages decodes every message published so far
+func (mq *MemoryQueue) PublishedMessages() ([]QueueMessage, error) {
+	var messages []QueueMessage
+	for _, body := range mq.Published() {
+		var queueMessage QueueMessage
+		if err := queueMessage.FromJSON(body); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal message: %w", err)
+		}
+		messages = append(messages, queueMessage)
+	}
+	return messages, nil
+}
@@ -0,0 +1,145 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func publishFileMessage(t *testing.T, mq *MemoryQueue, messageType string) {
+	t.Helper()
+
+	message, err := NewQueueMessage(messageType, FilePayload{Filename: "a.txt", Path: "uploads/x", ID: 1})
+	if err != nil {
+		t.Fatalf("NewQueueMessage: %v", err)
+	}
+	body, err := message.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON: %v", err)
+	}
+	if err := mq.PublishMessage(body); err != nil {
+		t.Fatalf("PublishMessage: %v", err)
+	}
+}
+
+// receiveAll closes the queue so ReceiveMessage returns once every pending message was handled
+func receiveAll(t *testing.T, mq *MemoryQueue, handler MessageHandler) {
+	t.Helper()
+
+	if err := mq.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := mq.ReceiveMessage(context.Background(), handler); err != nil {
+		t.Fatalf("ReceiveMessage: %v", err)
+	}
+}
+
+func TestMemoryQueueRetriesUntilSuccess(t *testing.T) {
+	mq := NewMemoryQueue()
+	publishFileMessage(t, mq, MessageTypeCompress)
+
+	var attempts []Attempt
+	receiveAll(t, mq, func(ctx context.Context, message QueueMessage) error {
+		attempt, _ := AttemptFromContext(ctx)
+		attempts = append(attempts, attempt)
+		if len(attempts) < 3 {
+			return errors.New("temporary failure")
+		}
+		return nil
+	})
+
+	if len(attempts) != 3 {
+		t.Fatalf("got %d attempts, want 3", len(attempts))
+	}
+	for i, attempt := range attempts {
+		if attempt.Retries != i || attempt.MaxRetries != defaultMaxRetries {
+			t.Errorf("attempt %d: got %+v", i, attempt)
+		}
+	}
+	if deadLetters := mq.DeadLetters(); len(deadLetters) != 0 {
+		t.Errorf("got %d dead letters, want none", len(deadLetters))
+	}
+}
+
+func TestMemoryQueueDeadLettersExhaustedMessages(t *testing.T) {
+	mq := NewMemoryQueue()
+	mq.RetryPolicies = map[string]RetryPolicy{MessageTypeThumbnail: {MaxRetries: 2}}
+	publishFileMessage(t, mq, MessageTypeThumbnail)
+
+	calls := 0
+	receiveAll(t, mq, func(ctx context.Context, message QueueMessage) error {
+		calls++
+		attempt, _ := AttemptFromContext(ctx)
+		if attempt.Last() != (calls == 3) {
+			t.Errorf("call %d: got Last() %v", calls, attempt.Last())
+		}
+		return errors.New("always fails")
+	})
+
+	if calls != 3 {
+		t.Errorf("got %d calls, want the first attempt and 2 retries", calls)
+	}
+	if deadLetters := mq.DeadLetters(); len(deadLetters) != 1 {
+		t.Errorf("got %d dead letters, want 1", len(deadLetters))
+	}
+}
+
+func TestMemoryQueueDeadLettersPermanentFailures(t *testing.T) {
+	mq := NewMemoryQueue()
+	publishFileMessage(t, mq, MessageTypeCleanup)
+
+	calls := 0
+	receiveAll(t, mq, func(ctx context.Context, message QueueMessage) error {
+		calls++
+		return Permanent(errors.New("file is gone"))
+	})
+
+	if calls != 1 {
+		t.Errorf("got %d calls, want 1", calls)
+	}
+	if deadLetters := mq.DeadLetters(); len(deadLetters) != 1 {
+		t.Errorf("got %d dead letters, want 1", len(deadLetters))
+	}
+}
+
+func TestMemoryQueueDeadLettersUndecodableMessages(t *testing.T) {
+	mq := NewMemoryQueue()
+	if err := mq.PublishMessage([]byte(`not json`)); err != nil {
+		t.Fatalf("PublishMessage: %v", err)
+	}
+
+	receiveAll(t, mq, func(ctx context.Context, message QueueMessage) error {
+		t.Error("handler called for an undecodable message")
+		return nil
+	})
+
+	if deadLetters := mq.DeadLetters(); len(deadLetters) != 1 || string(deadLetters[0]) != "not json" {
+		t.Errorf("got dead letters %q", deadLetters)
+	}
+}
+
+func TestMemoryQueueStopsOnCancel(t *testing.T) {
+	mq := NewMemoryQueue()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := mq.ReceiveMessage(ctx, func(context.Context, QueueMessage) error { return nil }); err != nil {
+			t.Errorf("ReceiveMessage: %v", err)
+		}
+	}()
+
+	cancel()
+	wg.Wait()
+
+	if mq.State() != StateConnected {
+		t.Errorf("got state %s, want connected until Close", mq.State())
+	}
+	mq.Close()
+	if err := mq.PublishMessage([]byte(`{}`)); err == nil {
+		t.Error("PublishMessage succeeded after Close")
+	}
+}
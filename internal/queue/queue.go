@@ -1,6 +1,7 @@
 package queue
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 )
@@ -15,7 +16,37 @@ const (
 // QueueConnection defines the interface for queue operations
 type QueueOperations interface {
 	PublishMessage([]byte) error
-	ReceiveMessage(chan<- QueueMessage) error
+	// ReceiveMessage consumes until ctx is cancelled; the message being handled at that point is
+	// still settled before it returns, unconsumed ones stay in the queue
+	ReceiveMessage(context.Context, MessageHandler) error
+	Close() error
+}
+
+// ConnectionState describes whether a queue can currently reach its broker
+type ConnectionState int
+
+const (
+	StateConnected ConnectionState = iota
+	StateReconnecting
+	StateClosed
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case StateConnected:
+		return "connected"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// stateReporter is implemented by connections that can lose their broker
+type stateReporter interface {
+	State() ConnectionState
 }
 
 // Queue encapsulates a specific queue connection implementation
@@ -49,6 +80,11 @@ func NewQueue(queueType QueueImplementation, config any) (*Queue, error) {
 	return &queue, nil
 }
 
+// NewQueueWithOperations wraps an existing implementation, such as a MemoryQueue in tests
+func NewQueueWithOperations(operations QueueOperations) *Queue {
+	return &Queue{connection: operations}
+}
+
 // PublishMessage sends a message to the queue
 func (q *Queue) PublishMessage(msg []byte) error {
 	if q.connection == nil {
@@ -58,11 +94,31 @@ func (q *Queue) PublishMessage(msg []byte) error {
 	return q.connection.PublishMessage(msg)
 }
 
-// ReceiveMessage reads messages from the queue and sends them to the provided channel
-func (q *Queue) ReceiveMessage(c chan<- QueueMessage) error {
+// ReceiveMessage reads messages from the queue and passes each one to handler, whose result
+// decides whether the message is acknowledged, retried or dead-lettered. It blocks until ctx is
+// cancelled and the message in progress has been settled.
+func (q *Queue) ReceiveMessage(ctx context.Context, handler MessageHandler) error {
 	if q.connection == nil {
 		return fmt.Errorf("queue connection not initialized")
 	}
 
-	return q.connection.ReceiveMessage(c)
+	return q.connection.ReceiveMessage(ctx, handler)
+}
+
+// State reports the connection state for logging and health checks; implementations without a
+// broker are always connected until closed
+func (q *Queue) State() ConnectionState {
+	if reporter, ok := q.connection.(stateReporter); ok {
+		return reporter.State()
+	}
+	return StateConnected
+}
+
+// Close releases the underlying connection; unacknowledged messages are redelivered later
+func (q *Queue) Close() error {
+	if q.connection == nil {
+		return nil
+	}
+
+	return q.connection.Close()
 }
@@ -2,8 +2,11 @@ package queue
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"sync"
 	"time"
 
 	"github.com/rabbitmq/amqp091-go"
@@ -13,22 +16,93 @@ type RabbitMQConfig struct {
 	URL       string
 	QueueName string
 	Timeout   time.Duration
+	// MaxRetries is how many times a failed message is retried before it is dead-lettered, defaults to 5
+	MaxRetries int
+	// RetryDelay is the wait before the first retry, doubled on every further one and rounded up to
+	// a retry queue delay; defaults to 1s
+	RetryDelay time.Duration
+	// RetryPolicies overrides MaxRetries and RetryDelay per message type
+	RetryPolicies map[string]RetryPolicy
+	// ReconnectDelay is the wait before the first reconnection attempt, doubled up to 30s; defaults to 1s
+	ReconnectDelay time.Duration
+	// Concurrency is how many messages are handled at once, and the prefetch count; defaults to 1
+	Concurrency int
+	// JobTimeout bounds the context each message is handled with; zero means no limit
+	JobTimeout time.Duration
+	// OnStateChange is called whenever the connection is lost, restored or closed
+	OnStateChange func(state ConnectionState, err error)
 }
 
+// RabbitMQConnection keeps a connection to RabbitMQ alive: when the broker drops it, a new one is
+// dialed in the background, consumers resume and publishes wait for it up to Timeout.
 type RabbitMQConnection struct {
 	config RabbitMQConfig
-	conn   *amqp091.Connection
+
+	mu    sync.Mutex
+	conn  *amqp091.Connection
+	state ConnectionState
+	// ready is closed while conn is usable and replaced when it is lost
+	ready chan struct{}
+	// done is closed by Close
+	done chan struct{}
 }
 
-// PublishMessage sends a message to the RabbitMQ queue
+// Queue topology derived from the queue name:
+// failed messages wait in <name>.retry.<delay> until the TTL of that queue expires and they
+// return to <name>, exhausted and poison messages go through the <name>.dlx exchange into <name>.dead.
+func (rc *RabbitMQConnection) deadLetterExchange() string  { return rc.config.QueueName + ".dlx" }
+func (rc *RabbitMQConnection) deadLetterQueueName() string { return rc.config.QueueName + ".dead" }
+
+func (rc *RabbitMQConnection) retryQueueName(tier time.Duration) string {
+	return fmt.Sprintf("%s.retry.%ds", rc.config.QueueName, int64(tier/time.Second))
+}
+
+// PublishMessage sends a message to the RabbitMQ queue and returns once the broker confirmed it.
+// During an outage it waits for the connection to come back and retries until Timeout (30s by
+// default) runs out.
 func (rc *RabbitMQConnection) PublishMessage(msg []byte) error {
-	channel, err := rc.conn.Channel()
+	timeout := rc.config.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	for attempt := 0; ; attempt++ {
+		err := rc.publish(ctx, msg)
+		if err == nil {
+			return nil
+		}
+		if errors.Is(err, errConnectionClosed) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to publish message: %w", err)
+		case <-time.After(backoff(100*time.Millisecond, time.Second, attempt)):
+		}
+	}
+}
+
+func (rc *RabbitMQConnection) publish(ctx context.Context, msg []byte) error {
+	conn, err := rc.connection(ctx)
+	if err != nil {
+		return err
+	}
+
+	channel, err := conn.Channel()
 	if err != nil {
 		return fmt.Errorf("failed to create channel: %w", err)
 	}
 
 	defer channel.Close()
 
+	if err := channel.Confirm(false); err != nil {
+		return fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+
 	messageProperties := amqp091.Publishing{
 		DeliveryMode: amqp091.Persistent,
 		Timestamp:    time.Now(),
@@ -36,69 +110,336 @@ func (rc *RabbitMQConnection) PublishMessage(msg []byte) error {
 		Body:         msg,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	return publishConfirmed(ctx, channel, rc.config.QueueName, messageProperties)
+}
+
+// publishConfirmed publishes msg on a channel in confirm mode and waits until the broker has
+// taken responsibility for it
+func publishConfirmed(ctx context.Context, channel *amqp091.Channel, queueName string, msg amqp091.Publishing) error {
+	confirmation, err := channel.PublishWithDeferredConfirmWithContext(ctx, "", queueName, false, false, msg)
+	if err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", queueName, err)
+	}
+
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to confirm publish to %s: %w", queueName, err)
+	}
+	if !acked {
+		return fmt.Errorf("broker rejected publish to %s", queueName)
+	}
+
+	return nil
+}
 
-	return channel.PublishWithContext(ctx, "", rc.config.QueueName, false, false, messageProperties)
+// ReceiveMessage listens for messages from the RabbitMQ queue and acknowledges each one only
+// after handler returns. Failed messages are retried with exponential backoff through the retry
+// queue; permanent failures, undecodable bodies and exhausted retries go to the dead-letter queue.
+// Cancelling ctx stops the consumer; prefetched messages are requeued when the channel closes.
+// When the connection is lost, consumption resumes once it has been restored.
+func (rc *RabbitMQConnection) ReceiveMessage(ctx context.Context, handler MessageHandler) error {
+	for attempt := 0; ; attempt++ {
+		conn, err := rc.connection(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		err = rc.consume(ctx, conn, handler)
+		if ctx.Err() != nil {
+			return err
+		}
+
+		log.Printf("consumer interrupted, resuming: %v", err)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff(100*time.Millisecond, maxReconnectDelay, attempt)):
+		}
+	}
 }
 
-// ReceiveMessage listens for messages from the RabbitMQ queue
-func (rc *RabbitMQConnection) ReceiveMessage(c chan<- QueueMessage) error {
-	channel, err := rc.conn.Channel()
+// consume runs one consumer on conn until ctx is cancelled or the channel is lost. Deliveries
+// are shared by Concurrency processors, and the prefetch count keeps at most that many unacked
+// messages on this worker.
+func (rc *RabbitMQConnection) consume(ctx context.Context, conn *amqp091.Connection, handler MessageHandler) error {
+	channel, err := conn.Channel()
 	if err != nil {
 		return fmt.Errorf("failed to create channel: %w", err)
 	}
 
 	defer channel.Close()
 
-	queue, err := channel.QueueDeclare(
+	concurrency := rc.concurrency()
+	if err := channel.Qos(concurrency, 0, false); err != nil {
+		return fmt.Errorf("failed to set prefetch count: %w", err)
+	}
+
+	// Retries are published on this channel and must be confirmed before the original is acked
+	if err := channel.Confirm(false); err != nil {
+		return fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+
+	consumerTag := fmt.Sprintf("%s-%d-%d", rc.config.QueueName, os.Getpid(), time.Now().UnixNano())
+
+	messages, err := channel.Consume(
 		rc.config.QueueName,
-		false, // durable
-		false, // autoDelete
+		consumerTag,
+		false, // autoAck
 		false, // exclusive
+		false, // noLocal
 		false, // noWait
 		nil,   // arguments
 	)
 	if err != nil {
-		return fmt.Errorf("failed to declare queue: %w", err)
+		return fmt.Errorf("failed to start consuming: %w", err)
 	}
 
-	messages, err := channel.Consume(
-		queue.Name,
-		"",    // consumer
-		true,  // autoAck
-		false, // exclusive
-		false, // noLocal
+	var wg sync.WaitGroup
+	processorErrs := make(chan error, concurrency)
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			processorErrs <- rc.process(ctx, channel, messages, handler)
+		}()
+	}
+
+	// Processors finish the message they are handling before returning
+	wg.Wait()
+	close(processorErrs)
+
+	if ctx.Err() != nil {
+		if err := channel.Cancel(consumerTag, false); err != nil {
+			return fmt.Errorf("failed to cancel consumer: %w", err)
+		}
+		return nil
+	}
+
+	return <-processorErrs
+}
+
+// process handles deliveries one at a time until ctx is cancelled or messages is closed
+func (rc *RabbitMQConnection) process(ctx context.Context, channel *amqp091.Channel, messages <-chan amqp091.Delivery, handler MessageHandler) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case msg, ok := <-messages:
+			if !ok {
+				return fmt.Errorf("consumer channel closed")
+			}
+
+			// select picks at random when both are ready, so a prefetched delivery can still arrive
+			// after cancellation. It is left unacked and redelivered once the channel closes.
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			if err := rc.handleDelivery(ctx, channel, msg, handler); err != nil {
+				log.Printf("failed to settle message: %v", err)
+			}
+		}
+	}
+}
+
+// handleDelivery runs handler on msg and acks, retries or dead-letters it according to the result
+func (rc *RabbitMQConnection) handleDelivery(ctx context.Context, channel *amqp091.Channel, msg amqp091.Delivery, handler MessageHandler) error {
+	var queueMessage QueueMessage
+
+	if err := queueMessage.FromJSON(msg.Body); err != nil {
+		log.Printf("failed to unmarshal message, dead-lettering it: %v", err)
+		return msg.Reject(false)
+	}
+
+	policy := rc.retryPolicy(queueMessage.Type)
+	attempt := Attempt{Retries: retryCount(msg.Headers), MaxRetries: policy.MaxRetries}
+
+	jobCtx, cancel := rc.jobContext(ctx)
+	err := handler(contextWithAttempt(jobCtx, attempt), queueMessage)
+	cancel()
+	if err == nil {
+		return msg.Ack(false)
+	}
+
+	retries := attempt.Retries
+	if IsPermanent(err) || attempt.Last() {
+		log.Printf("message failed after %d retries, dead-lettering it: %v", retries, err)
+		return msg.Nack(false, false)
+	}
+
+	if err := rc.scheduleRetry(channel, msg, retries+1, retryDelay(policy.Delay, retries)); err != nil {
+		// Put the message back as is rather than lose it
+		log.Printf("failed to schedule retry: %v", err)
+		return msg.Nack(false, true)
+	}
+
+	return msg.Ack(false)
+}
+
+// scheduleRetry republishes msg to the retry queue of the shortest tier that covers delay and
+// waits for the broker to confirm it, so acking the original afterwards cannot lose the message
+func (rc *RabbitMQConnection) scheduleRetry(channel *amqp091.Channel, msg amqp091.Delivery, retries int, delay time.Duration) error {
+	headers := amqp091.Table{}
+	for key, value := range msg.Headers {
+		headers[key] = value
+	}
+	headers[RetryCountHeader] = int32(retries)
+
+	messageProperties := amqp091.Publishing{
+		Headers:      headers,
+		DeliveryMode: amqp091.Persistent,
+		Timestamp:    time.Now(),
+		ContentType:  msg.ContentType,
+		Body:         msg.Body,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return publishConfirmed(ctx, channel, rc.retryQueueName(retryTier(delay)), messageProperties)
+}
+
+// jobContext detaches the handler from consumer cancellation and applies JobTimeout
+func (rc *RabbitMQConnection) jobContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx = context.WithoutCancel(ctx)
+	if rc.config.JobTimeout > 0 {
+		return context.WithTimeout(ctx, rc.config.JobTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+func (rc *RabbitMQConnection) concurrency() int {
+	if rc.config.Concurrency <= 0 {
+		return 1
+	}
+	return rc.config.Concurrency
+}
+
+func (rc *RabbitMQConnection) retryPolicy(messageType string) RetryPolicy {
+	return resolveRetryPolicy(rc.config.RetryPolicies, messageType, rc.config.MaxRetries, rc.config.RetryDelay)
+}
+
+// declareTopology declares the durable work, retry and dead-letter queues on conn
+func (rc *RabbitMQConnection) declareTopology(conn *amqp091.Connection) error {
+	channel, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to create channel: %w", err)
+	}
+
+	defer channel.Close()
+
+	err = channel.ExchangeDeclare(
+		rc.deadLetterExchange(),
+		"direct",
+		true,  // durable
+		false, // autoDelete
+		false, // internal
 		false, // noWait
 		nil,   // arguments
 	)
 	if err != nil {
-		return fmt.Errorf("failed to start consuming: %w", err)
+		return fmt.Errorf("failed to declare dead-letter exchange: %w", err)
+	}
+
+	if _, err := channel.QueueDeclare(rc.deadLetterQueueName(), true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare dead-letter queue: %w", err)
 	}
 
-	for msg := range messages {
-		var queueMessage QueueMessage
+	err = channel.QueueBind(rc.deadLetterQueueName(), rc.config.QueueName, rc.deadLetterExchange(), false, nil)
+	if err != nil {
+		return fmt.Errorf("failed to bind dead-letter queue: %w", err)
+	}
 
-		if err := queueMessage.FromJSON(msg.Body); err != nil {
-			log.Printf("failed to unmarshal message: %v", err)
-			continue
+	_, err = channel.QueueDeclare(
+		rc.config.QueueName,
+		true,  // durable
+		false, // autoDelete
+		false, // exclusive
+		false, // noWait
+		amqp091.Table{
+			"x-dead-letter-exchange":    rc.deadLetterExchange(),
+			"x-dead-letter-routing-key": rc.config.QueueName,
+		},
+	)
+	if err != nil {
+		var amqpErr *amqp091.Error
+		if errors.As(err, &amqpErr) && amqpErr.Code == amqp091.PreconditionFailed {
+			// Queues created before dead-lettering have other arguments, see "Queue migration" in the README
+			return fmt.Errorf("failed to declare queue: %q exists with different arguments, drain and delete it or use a new queue name: %w", rc.config.QueueName, err)
 		}
+		return fmt.Errorf("failed to declare queue: %w", err)
+	}
 
-		c <- queueMessage
+	// Every delay has its own queue whose TTL applies to all of its messages, so they expire in
+	// the order they arrived and are dead-lettered through the default exchange back into the work queue
+	for _, tier := range retryTiers {
+		_, err = channel.QueueDeclare(
+			rc.retryQueueName(tier),
+			true,  // durable
+			false, // autoDelete
+			false, // exclusive
+			false, // noWait
+			amqp091.Table{
+				"x-message-ttl":             tier.Milliseconds(),
+				"x-dead-letter-exchange":    "",
+				"x-dead-letter-routing-key": rc.config.QueueName,
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to declare retry queue: %w", err)
+		}
 	}
 
 	return nil
 }
 
-// createRabbitMQConnection initializes a new RabbitMQ connection
+// retryCount reads RetryCountHeader, which decodes as different integer types depending on the publisher
+func retryCount(headers amqp091.Table) int {
+	switch count := headers[RetryCountHeader].(type) {
+	case int:
+		return count
+	case int16:
+		return int(count)
+	case int32:
+		return int(count)
+	case int64:
+		return int(count)
+	default:
+		return 0
+	}
+}
+
+// createRabbitMQConnection initializes a new RabbitMQ connection, declares the queue topology
+// and starts watching the connection. The first dial is not retried so misconfiguration fails fast.
 func createRabbitMQConnection(cfg RabbitMQConfig) (*RabbitMQConnection, error) {
 	conn, err := amqp091.Dial(cfg.URL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
 	}
 
-	return &RabbitMQConnection{
+	ready := make(chan struct{})
+	close(ready)
+
+	connection := &RabbitMQConnection{
 		config: cfg,
 		conn:   conn,
-	}, nil
+		state:  StateConnected,
+		ready:  ready,
+		done:   make(chan struct{}),
+	}
+
+	if err := connection.declareTopology(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	go connection.watch(conn)
+
+	return connection, nil
 }
@@ -0,0 +1,149 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	defaultReconnectDelay = time.Second
+	maxReconnectDelay     = 30 * time.Second
+)
+
+var errConnectionClosed = errors.New("queue connection closed")
+
+// connection waits until the broker is reachable and returns the current connection.
+// It fails once ctx is done or Close was called.
+func (rc *RabbitMQConnection) connection(ctx context.Context) (*amqp091.Connection, error) {
+	rc.mu.Lock()
+	conn, ready := rc.conn, rc.ready
+	rc.mu.Unlock()
+
+	select {
+	case <-ready:
+		return conn, nil
+	case <-rc.done:
+		return nil, errConnectionClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// State reports whether the broker is currently reachable
+func (rc *RabbitMQConnection) State() ConnectionState {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	return rc.state
+}
+
+// setState records state and reports the change through OnStateChange
+func (rc *RabbitMQConnection) setState(state ConnectionState, err error) {
+	rc.mu.Lock()
+	changed := rc.state != state
+	rc.state = state
+	rc.mu.Unlock()
+
+	if changed && rc.config.OnStateChange != nil {
+		rc.config.OnStateChange(state, err)
+	}
+}
+
+// watch replaces the connection whenever the broker drops it, until Close is called
+func (rc *RabbitMQConnection) watch(conn *amqp091.Connection) {
+	for {
+		closeErr, ok := <-conn.NotifyClose(make(chan *amqp091.Error, 1))
+
+		select {
+		case <-rc.done:
+			return
+		default:
+		}
+
+		// A nil error means the connection was closed on purpose
+		if !ok || closeErr == nil {
+			closeErr = amqp091.ErrClosed
+		}
+
+		rc.mu.Lock()
+		rc.ready = make(chan struct{})
+		rc.mu.Unlock()
+		rc.setState(StateReconnecting, closeErr)
+
+		conn = rc.reconnect()
+		if conn == nil {
+			return
+		}
+	}
+}
+
+// reconnect dials with exponential backoff and redeclares the topology, returning nil if Close
+// is called in the meantime
+func (rc *RabbitMQConnection) reconnect() *amqp091.Connection {
+	base := rc.config.ReconnectDelay
+	if base <= 0 {
+		base = defaultReconnectDelay
+	}
+
+	for attempt := 0; ; attempt++ {
+		select {
+		case <-rc.done:
+			return nil
+		case <-time.After(backoff(base, maxReconnectDelay, attempt)):
+		}
+
+		conn, err := amqp091.Dial(rc.config.URL)
+		if err != nil {
+			log.Printf("failed to reconnect to RabbitMQ (attempt %d): %v", attempt+1, err)
+			continue
+		}
+
+		if err := rc.declareTopology(conn); err != nil {
+			log.Printf("failed to restore queue topology (attempt %d): %v", attempt+1, err)
+			conn.Close()
+			continue
+		}
+
+		rc.mu.Lock()
+		select {
+		case <-rc.done:
+			// Closed while dialing; the new connection is not needed
+			rc.mu.Unlock()
+			conn.Close()
+			return nil
+		default:
+		}
+		rc.conn = conn
+		close(rc.ready)
+		rc.mu.Unlock()
+		rc.setState(StateConnected, nil)
+
+		return conn
+	}
+}
+
+// Close stops reconnecting and closes the connection to RabbitMQ along with its channels
+func (rc *RabbitMQConnection) Close() error {
+	rc.mu.Lock()
+	select {
+	case <-rc.done:
+		rc.mu.Unlock()
+		return nil
+	default:
+	}
+	close(rc.done)
+	conn := rc.conn
+	rc.mu.Unlock()
+
+	rc.setState(StateClosed, nil)
+
+	if err := conn.Close(); err != nil && !errors.Is(err, amqp091.ErrClosed) {
+		return fmt.Errorf("failed to close connection: %w", err)
+	}
+	return nil
+}
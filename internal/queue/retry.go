@@ -0,0 +1,126 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	// RetryCountHeader counts how many times a message was sent back for another attempt
+	RetryCountHeader = "x-retry-count"
+
+	defaultMaxRetries = 5
+	defaultRetryDelay = time.Second
+	maxRetryDelay     = 10 * time.Minute
+)
+
+// MessageHandler processes one message. Returning nil acknowledges it, an error schedules a
+// retry and an error wrapped with Permanent dead-letters it straight away. ctx expires with the
+// job timeout but is not cancelled when consumption stops, so a shutdown lets the job finish.
+type MessageHandler func(ctx context.Context, message QueueMessage) error
+
+// RetryPolicy controls how often and how soon failed messages of one type are retried; zero
+// fields fall back to the queue-wide settings
+type RetryPolicy struct {
+	MaxRetries int
+	// Delay is the wait before the first retry, doubled on every further one; RabbitMQ rounds it up
+	// to the next retry queue delay of 1s, 5s, 30s, 2m or 10m
+	Delay time.Duration
+}
+
+// resolveRetryPolicy picks the policy of messageType from policies and fills its zero fields
+// from the queue-wide maxRetries and delay, or from the package defaults
+func resolveRetryPolicy(policies map[string]RetryPolicy, messageType string, maxRetries int, delay time.Duration) RetryPolicy {
+	policy := policies[messageType]
+	if policy.MaxRetries <= 0 {
+		policy.MaxRetries = maxRetries
+	}
+	if policy.MaxRetries <= 0 {
+		policy.MaxRetries = defaultMaxRetries
+	}
+	if policy.Delay <= 0 {
+		policy.Delay = delay
+	}
+	if policy.Delay <= 0 {
+		policy.Delay = defaultRetryDelay
+	}
+	return policy
+}
+
+// Attempt describes the delivery a handler is processing
+type Attempt struct {
+	// Retries is how many times the message was retried before this delivery
+	Retries    int
+	MaxRetries int
+}
+
+// Last reports whether a failure of this attempt dead-letters the message
+func (a Attempt) Last() bool {
+	return a.Retries >= a.MaxRetries
+}
+
+type attemptKey struct{}
+
+func contextWithAttempt(ctx context.Context, attempt Attempt) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attempt)
+}
+
+// AttemptFromContext returns the attempt of the message a handler was called with
+func AttemptFromContext(ctx context.Context) (Attempt, bool) {
+	attempt, ok := ctx.Value(attemptKey{}).(Attempt)
+	return attempt, ok
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying, such as a message pointing at a deleted file
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent
+func IsPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
+
+// retryTiers are the delays a retry can wait out. RabbitMQ only expires messages at the head of
+// a queue, so each delay has its own retry queue rather than one queue with per-message TTLs,
+// where a short retry would wait behind a longer one.
+var retryTiers = []time.Duration{time.Second, 5 * time.Second, 30 * time.Second, 2 * time.Minute, maxRetryDelay}
+
+// retryTier rounds delay up to the nearest tier
+func retryTier(delay time.Duration) time.Duration {
+	for _, tier := range retryTiers {
+		if delay <= tier {
+			return tier
+		}
+	}
+	return retryTiers[len(retryTiers)-1]
+}
+
+// retryDelay doubles base for every previous retry, capped at maxRetryDelay
+func retryDelay(base time.Duration, retries int) time.Duration {
+	return backoff(base, maxRetryDelay, retries)
+}
+
+// backoff doubles base for every previous attempt, capped at limit
+func backoff(base, limit time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 0; i < attempts && delay < limit; i++ {
+		delay *= 2
+	}
+	if delay > limit {
+		delay = limit
+	}
+	return delay
+}
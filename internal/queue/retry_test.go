@@ -0,0 +1,71 @@
+package queue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryTier(t *testing.T) {
+	tests := []struct {
+		delay time.Duration
+		want  time.Duration
+	}{
+		{delay: 0, want: time.Second},
+		{delay: time.Second, want: time.Second},
+		{delay: 2 * time.Second, want: 5 * time.Second},
+		{delay: 20 * time.Second, want: 30 * time.Second},
+		{delay: 80 * time.Second, want: 2 * time.Minute},
+		{delay: 5 * time.Minute, want: 10 * time.Minute},
+		{delay: time.Hour, want: 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := retryTier(tt.delay); got != tt.want {
+			t.Errorf("retryTier(%s) = %s, want %s", tt.delay, got, tt.want)
+		}
+	}
+}
+
+func TestRetryDelayDoublesUpToTheLimit(t *testing.T) {
+	tests := []struct {
+		retries int
+		want    time.Duration
+	}{
+		{retries: 0, want: 5 * time.Second},
+		{retries: 1, want: 10 * time.Second},
+		{retries: 3, want: 40 * time.Second},
+		{retries: 20, want: maxRetryDelay},
+	}
+
+	for _, tt := range tests {
+		if got := retryDelay(5*time.Second, tt.retries); got != tt.want {
+			t.Errorf("retryDelay(5s, %d) = %s, want %s", tt.retries, got, tt.want)
+		}
+	}
+}
+
+func TestResolveRetryPolicy(t *testing.T) {
+	policies := map[string]RetryPolicy{
+		MessageTypeThumbnail: {MaxRetries: 2},
+		MessageTypeCleanup:   {Delay: 30 * time.Second},
+	}
+
+	tests := []struct {
+		messageType string
+		want        RetryPolicy
+	}{
+		{messageType: MessageTypeThumbnail, want: RetryPolicy{MaxRetries: 2, Delay: 3 * time.Second}},
+		{messageType: MessageTypeCleanup, want: RetryPolicy{MaxRetries: 4, Delay: 30 * time.Second}},
+		{messageType: MessageTypeCompress, want: RetryPolicy{MaxRetries: 4, Delay: 3 * time.Second}},
+	}
+
+	for _, tt := range tests {
+		if got := resolveRetryPolicy(policies, tt.messageType, 4, 3*time.Second); got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.messageType, got, tt.want)
+		}
+	}
+
+	if got := resolveRetryPolicy(nil, MessageTypeCompress, 0, 0); got.MaxRetries != defaultMaxRetries || got.Delay != defaultRetryDelay {
+		t.Errorf("got %+v, want the package defaults", got)
+	}
+}
@@ -0,0 +1,121 @@
+package system
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/yansilvacerqueira/api-files/internal/auth"
+	jobRepository "github.com/yansilvacerqueira/api-files/internal/jobs/repository"
+	"github.com/yansilvacerqueira/api-files/internal/queue"
+)
+
+const pingTimeout = 2 * time.Second
+
+// Handler serves the operational endpoints of the API
+type Handler struct {
+	db     *sql.DB
+	logger *log.Logger
+	jobs   *jobRepository.JobRepository
+	queue  *queue.Queue
+	auth   *auth.Handler
+}
+
+type Config struct {
+	DB     *sql.DB
+	Logger *log.Logger
+	Auth   *auth.Handler
+	// Queue is the queue the API publishes jobs to; its connection state is reported
+	Queue *queue.Queue
+}
+
+func NewHandler(cfg Config) (*Handler, error) {
+	if cfg.DB == nil {
+		return nil, errors.New("database connection is required")
+	}
+	if cfg.Auth == nil {
+		return nil, errors.New("auth handler is required")
+	}
+	if cfg.Queue == nil {
+		return nil, errors.New("queue is required")
+	}
+
+	logger := cfg.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	return &Handler{
+		db:     cfg.DB,
+		logger: logger,
+		jobs:   jobRepository.NewJobRepository(cfg.DB),
+		queue:  cfg.Queue,
+		auth:   cfg.Auth,
+	}, nil
+}
+
+// getStatus reports the database pool, the queue connection and the job counts per status
+func (h *Handler) getStatus(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), pingTimeout)
+	defer cancel()
+
+	databaseStatus := h.databaseStatus(ctx)
+
+	var jobs interface{}
+	if databaseStatus == "up" {
+		counts, err := h.jobs.CountJobsByStatus(ctx)
+		if err != nil {
+			h.logger.Printf("Error counting jobs: %v", err)
+		} else {
+			jobs = counts
+		}
+	}
+
+	stats := h.db.Stats()
+	h.respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"database": map[string]interface{}{
+			"status":           databaseStatus,
+			"open_connections": stats.OpenConnections,
+			"in_use":           stats.InUse,
+			"idle":             stats.Idle,
+			"wait_count":       stats.WaitCount,
+		},
+		"queue": map[string]interface{}{
+			"state": h.queue.State().String(),
+		},
+		"jobs": jobs,
+	})
+}
+
+// getHealth is the unauthenticated probe for load balancers and orchestrators: 200 while the
+// database answers and the queue is connected, 503 otherwise
+func (h *Handler) getHealth(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), pingTimeout)
+	defer cancel()
+
+	databaseStatus := h.databaseStatus(ctx)
+	queueState := h.queue.State()
+
+	status, code := "ok", http.StatusOK
+	if databaseStatus != "up" || queueState != queue.StateConnected {
+		status, code = "degraded", http.StatusServiceUnavailable
+	}
+
+	h.respondWithJSON(w, code, map[string]string{
+		"status":   status,
+		"database": databaseStatus,
+		"queue":    queueState.String(),
+	})
+}
+
+// databaseStatus pings the database, reporting "up" or "down"
+func (h *Handler) databaseStatus(ctx context.Context) string {
+	if err := h.db.PingContext(ctx); err != nil {
+		h.logger.Printf("Error pinging the database: %v", err)
+		return "down"
+	}
+	return "up"
+}
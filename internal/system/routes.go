@@ -0,0 +1,30 @@
+package system
+
+import (
+	"net/http"
+
+	userEntity "github.com/yansilvacerqueira/api-files/internal/users/entity"
+)
+
+func (h *Handler) SetRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/api/system/status", h.auth.RequirePermission(userEntity.PermissionSystem, h.handleStatus))
+	mux.HandleFunc("/healthz", h.handleHealth)
+}
+
+func (h *Handler) handleHealth(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		h.getHealth(w, r)
+	default:
+		h.respondWithError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (h *Handler) handleStatus(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.getStatus(w, r)
+	default:
+		h.respondWithError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
@@ -26,6 +26,22 @@ type User struct {
 	UpdatedAt time.Time
 	Deleted   bool
 	LastLogin *time.Time
+	Role      Role
+	Status    UserStatus
+	// FailedLoginAttempts counts consecutive failed logins since the last success or lockout
+	FailedLoginAttempts int
+	// LockedUntil is set while a temporary lockout after repeated failed logins is in effect
+	LockedUntil *time.Time
+	// LockoutActive reports whether LockedUntil was still ahead when the user was loaded. The
+	// database compares it against its own clock, since locked_until carries no time zone.
+	LockoutActive bool
+	// EmailVerifiedAt stays nil until the user follows the verification link sent to Email
+	EmailVerifiedAt *time.Time
+	// PasswordChangedAt is when the password was last changed
+	PasswordChangedAt *time.Time
+	// TokenVersion is embedded in every token issued to the user and bumped by the database on each
+	// password change, so the change revokes all earlier tokens regardless of clocks
+	TokenVersion int
 }
 
 type UserStatus struct {
@@ -54,6 +70,7 @@ func NewUser(fullName, email, password string) (*User, error) {
 		Email:     email,
 		CreatedAt: now,
 		UpdatedAt: now,
+		Role:      RoleMember,
 	}
 
 	if err := u.SetPassword(password); err != nil {
@@ -77,8 +94,10 @@ func (u *User) SetPassword(password string) error {
 		return fmt.Errorf("failed to hash password: %w", err)
 	}
 
+	now := time.Now()
 	u.Password = hashedPassword
-	u.UpdatedAt = time.Now()
+	u.PasswordChangedAt = &now
+	u.UpdatedAt = now
 	return nil
 }
 
@@ -87,12 +106,48 @@ func (u *User) ValidatePassword(password string) bool {
 	return err == nil
 }
 
+// AcceptsTokenVersion reports whether a token carrying version was issued since the last password change
+func (u *User) AcceptsTokenVersion(version int) bool {
+	return version == u.TokenVersion
+}
+
 func (u *User) UpdateLastLogin() {
 	now := time.Now()
 	u.LastLogin = &now
 	u.UpdatedAt = now
 }
 
+// IsLocked reports whether the account is locked by an administrator or by a temporary lockout
+func (u *User) IsLocked() bool {
+	return u.Status.IsLocked || u.LockoutActive
+}
+
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+func (u *User) MarkEmailVerified() {
+	now := time.Now()
+	u.EmailVerifiedAt = &now
+	u.UpdatedAt = now
+}
+
+// ChangeEmail validates and sets a new address, which must be verified again
+func (u *User) ChangeEmail(email string) error {
+	email = strings.TrimSpace(strings.ToLower(email))
+	if err := ValidateEmail(email); err != nil {
+		return err
+	}
+	if email == u.Email {
+		return nil
+	}
+
+	u.Email = email
+	u.EmailVerifiedAt = nil
+	u.UpdatedAt = time.Now()
+	return nil
+}
+
 func (u *User) SoftDelete() {
 	u.Deleted = true
 	u.UpdatedAt = time.Now()
@@ -144,10 +199,13 @@ func validatePassword(password string) error {
 
 func (u *User) Sanitize() map[string]interface{} {
 	return map[string]interface{}{
-		"id":         u.ID,
-		"full_name":  u.FullName,
-		"email":      u.Email,
-		"created_at": u.CreatedAt,
-		"last_login": u.LastLogin,
+		"id":             u.ID,
+		"full_name":      u.FullName,
+		"email":          u.Email,
+		"created_at":     u.CreatedAt,
+		"last_login":     u.LastLogin,
+		"role":           u.Role,
+		"is_locked":      u.IsLocked(),
+		"email_verified": u.IsEmailVerified(),
 	}
 }
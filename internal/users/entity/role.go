@@ -0,0 +1,55 @@
+package entity
+
+import "errors"
+
+var ErrInvalidRole = errors.New("role must be one of admin, member or read_only")
+
+type Role string
+
+const (
+	RoleAdmin    Role = "admin"
+	RoleMember   Role = "member"
+	RoleReadOnly Role = "read_only"
+)
+
+// Permission is an action a role may be allowed to perform
+type Permission int
+
+const (
+	// PermissionRead allows reading files, folders and user profiles
+	PermissionRead Permission = iota
+	// PermissionWrite allows creating and changing files and folders
+	PermissionWrite
+	// PermissionManageUsers allows listing every user, changing roles and hard deletes
+	PermissionManageUsers
+	// PermissionSystem allows operational endpoints
+	PermissionSystem
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleAdmin:    {PermissionRead, PermissionWrite, PermissionManageUsers, PermissionSystem},
+	RoleMember:   {PermissionRead, PermissionWrite},
+	RoleReadOnly: {PermissionRead},
+}
+
+func ParseRole(value string) (Role, error) {
+	role := Role(value)
+	if _, ok := rolePermissions[role]; !ok {
+		return "", ErrInvalidRole
+	}
+	return role, nil
+}
+
+// Can reports whether the role grants the permission; unknown roles grant nothing
+func (r Role) Can(permission Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Role) IsAdmin() bool {
+	return r == RoleAdmin
+}
@@ -9,6 +9,7 @@ import (
 	"path"
 	"strconv"
 
+	"github.com/yansilvacerqueira/api-files/internal/auth"
 	"github.com/yansilvacerqueira/api-files/internal/users/entity"
 	"github.com/yansilvacerqueira/api-files/internal/users/repository"
 )
@@ -17,11 +18,13 @@ type Handler struct {
 	db     *sql.DB
 	logger *log.Logger
 	repo   *repository.UserRepository
+	auth   *auth.Handler
 }
 
 type Config struct {
 	DB     *sql.DB
 	Logger *log.Logger
+	Auth   *auth.Handler
 }
 
 type createUserRequest struct {
@@ -36,10 +39,17 @@ type updateUserRequest struct {
 	Password string `json:"password,omitempty"`
 }
 
+type updateRoleRequest struct {
+	Role string `json:"role"`
+}
+
 func NewHandler(cfg Config) (*Handler, error) {
 	if cfg.DB == nil {
 		return nil, errors.New("database connection is required")
 	}
+	if cfg.Auth == nil {
+		return nil, errors.New("auth handler is required")
+	}
 
 	logger := cfg.Logger
 	if logger == nil {
@@ -52,6 +62,7 @@ func NewHandler(cfg Config) (*Handler, error) {
 		db:     cfg.DB,
 		logger: logger,
 		repo:   repo,
+		auth:   cfg.Auth,
 	}, nil
 }
 
@@ -79,6 +90,10 @@ func (h *Handler) getUserByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !h.authorizeUserAccess(w, r, id) {
+		return
+	}
+
 	ctx := r.Context()
 	user, err := h.repo.GetUserByID(ctx, id)
 	if err != nil {
@@ -110,6 +125,11 @@ func (h *Handler) createUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The account exists either way; a failed mail can be retried through the resend endpoint
+	if err := h.auth.SendVerification(ctx, user); err != nil {
+		h.logger.Printf("Error sending verification to user %d: %v", user.ID, err)
+	}
+
 	h.respondWithJSON(w, http.StatusCreated, user.Sanitize())
 }
 
@@ -120,6 +140,14 @@ func (h *Handler) updateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !h.authorizeUserAccess(w, r, id) {
+		return
+	}
+
+	h.updateUserByID(w, r, id)
+}
+
+func (h *Handler) updateUserByID(w http.ResponseWriter, r *http.Request, id int64) {
 	var req updateUserRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		h.respondWithError(w, http.StatusBadRequest, "invalid request payload")
@@ -136,12 +164,12 @@ func (h *Handler) updateUser(w http.ResponseWriter, r *http.Request) {
 	if req.FullName != "" {
 		user.FullName = req.FullName
 	}
+	previousEmail := user.Email
 	if req.Email != "" {
-		if err := entity.ValidateEmail(req.Email); err != nil {
+		if err := user.ChangeEmail(req.Email); err != nil {
 			h.respondWithError(w, http.StatusBadRequest, err.Error())
 			return
 		}
-		user.Email = req.Email
 	}
 	if req.Password != "" {
 		if err := user.SetPassword(req.Password); err != nil {
@@ -156,6 +184,12 @@ func (h *Handler) updateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if user.Email != previousEmail {
+		if err := h.auth.SendVerification(ctx, user); err != nil {
+			h.logger.Printf("Error sending verification to user %d: %v", user.ID, err)
+		}
+	}
+
 	h.respondWithJSON(w, http.StatusOK, user.Sanitize())
 }
 
@@ -166,6 +200,80 @@ func (h *Handler) deleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if r.URL.Query().Get("hard") == "true" {
+		if !auth.Authorize(r.Context(), entity.PermissionManageUsers) {
+			h.respondWithError(w, http.StatusForbidden, "forbidden")
+			return
+		}
+
+		h.hardDeleteUser(w, r, id)
+		return
+	}
+
+	if !h.authorizeUserAccess(w, r, id) {
+		return
+	}
+
+	h.deleteUserByID(w, r, id)
+}
+
+func (h *Handler) hardDeleteUser(w http.ResponseWriter, r *http.Request, id int64) {
+	ctx := r.Context()
+	if err := h.repo.HardDeleteUser(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			h.respondWithError(w, http.StatusNotFound, "user not found")
+			return
+		}
+
+		h.logger.Printf("Error hard deleting user %d: %v", id, err)
+		h.respondWithError(w, http.StatusInternalServerError, "failed to delete user")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, map[string]string{"message": "user permanently deleted"})
+}
+
+func (h *Handler) updateUserRole(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(path.Base(path.Dir(r.URL.Path)), 10, 64)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	var req updateRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	role, err := entity.ParseRole(req.Role)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.repo.UpdateRole(ctx, id, role); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			h.respondWithError(w, http.StatusNotFound, "user not found")
+			return
+		}
+
+		h.logger.Printf("Error updating role of user %d: %v", id, err)
+		h.respondWithError(w, http.StatusInternalServerError, "failed to update role")
+		return
+	}
+
+	user, err := h.repo.GetUserByID(ctx, id)
+	if err != nil {
+		h.respondWithError(w, http.StatusNotFound, "user not found")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, user.Sanitize())
+}
+
+func (h *Handler) deleteUserByID(w http.ResponseWriter, r *http.Request, id int64) {
 	ctx := r.Context()
 	if err := h.repo.DeleteUser(ctx, id); err != nil {
 		h.logger.Printf("Error deleting user %d: %v", id, err)
@@ -178,7 +286,7 @@ func (h *Handler) deleteUser(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handler) handleUserProfile(w http.ResponseWriter, r *http.Request) {
 	// Get user ID from context (requires authentication middleware)
-	userID, ok := r.Context().Value("userID").(int64)
+	userID, ok := auth.UserIDFromContext(r.Context())
 	if !ok {
 		h.respondWithError(w, http.StatusUnauthorized, "unauthorized")
 		return
@@ -193,3 +301,76 @@ func (h *Handler) handleUserProfile(w http.ResponseWriter, r *http.Request) {
 
 	h.respondWithJSON(w, http.StatusOK, user.Sanitize())
 }
+
+func (h *Handler) updateUserProfile(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondWithError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	h.updateUserByID(w, r, userID)
+}
+
+func (h *Handler) deleteUserProfile(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondWithError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	h.deleteUserByID(w, r, userID)
+}
+
+func (h *Handler) lockUser(w http.ResponseWriter, r *http.Request) {
+	h.setUserLocked(w, r, true)
+}
+
+func (h *Handler) unlockUser(w http.ResponseWriter, r *http.Request) {
+	h.setUserLocked(w, r, false)
+}
+
+func (h *Handler) setUserLocked(w http.ResponseWriter, r *http.Request, locked bool) {
+	id, err := strconv.ParseInt(path.Base(path.Dir(r.URL.Path)), 10, 64)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.repo.SetLocked(ctx, id, locked); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			h.respondWithError(w, http.StatusNotFound, "user not found")
+			return
+		}
+
+		h.logger.Printf("Error setting lock of user %d to %t: %v", id, locked, err)
+		h.respondWithError(w, http.StatusInternalServerError, "failed to update account lock")
+		return
+	}
+
+	user, err := h.repo.GetUserByID(ctx, id)
+	if err != nil {
+		h.respondWithError(w, http.StatusNotFound, "user not found")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, user.Sanitize())
+}
+
+// authorizeUserAccess only lets the authenticated user read or change their own account, unless they are an administrator
+func (h *Handler) authorizeUserAccess(w http.ResponseWriter, r *http.Request, id int64) bool {
+	ctx := r.Context()
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		h.respondWithError(w, http.StatusUnauthorized, "unauthorized")
+		return false
+	}
+
+	if userID != id && !auth.Authorize(ctx, entity.PermissionManageUsers) {
+		h.respondWithError(w, http.StatusForbidden, "forbidden")
+		return false
+	}
+
+	return true
+}
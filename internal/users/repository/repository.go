@@ -4,14 +4,28 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/yansilvacerqueira/api-files/internal/users/entity"
 )
 
 var (
 	ErrUserNotFound = errors.New("user not found")
+	ErrAdminExists  = errors.New("an administrator already exists")
+	ErrUserLocked   = errors.New("user is locked")
 )
 
+// userColumns is the column list scanUser expects, in order. Whether a lockout is still running is
+// decided against the database clock, which is the one locked_until was written with.
+const userColumns = "id, full_name, email, password, created_at, updated_at, last_login, deleted, role, " +
+	"is_locked, locked_until, COALESCE(locked_until > NOW(), false), failed_login_attempts, login_count, " +
+	"last_login_ip, email_verified_at, password_changed_at, token_version"
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
 type UserRepository struct {
 	db *sql.DB
 }
@@ -20,13 +34,47 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 	return &UserRepository{db: db}
 }
 
+func scanUser(s scanner) (*entity.User, error) {
+	user := &entity.User{}
+	var lastLoginIP sql.NullString
+
+	err := s.Scan(
+		&user.ID,
+		&user.FullName,
+		&user.Email,
+		&user.Password,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.LastLogin,
+		&user.Deleted,
+		&user.Role,
+		&user.Status.IsLocked,
+		&user.LockedUntil,
+		&user.LockoutActive,
+		&user.FailedLoginAttempts,
+		&user.Status.LoginCount,
+		&lastLoginIP,
+		&user.EmailVerifiedAt,
+		&user.PasswordChangedAt,
+		&user.TokenVersion,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	user.Status.IsActive = !user.Deleted
+	user.Status.LastLoginIP = lastLoginIP.String
+
+	return user, nil
+}
+
 func (r *UserRepository) GetUsers(ctx context.Context) ([]entity.User, error) {
-	query := `
-		SELECT id, full_name, email, password, created_at, updated_at, last_login, deleted
+	query := fmt.Sprintf(`
+		SELECT %s
 		FROM users
 		WHERE deleted = false
 		ORDER BY created_at DESC
-	`
+	`, userColumns)
 
 	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
@@ -36,45 +84,42 @@ func (r *UserRepository) GetUsers(ctx context.Context) ([]entity.User, error) {
 
 	var users []entity.User
 	for rows.Next() {
-		var user entity.User
-		err := rows.Scan(
-			&user.ID,
-			&user.FullName,
-			&user.Email,
-			&user.Password,
-			&user.CreatedAt,
-			&user.UpdatedAt,
-			&user.LastLogin,
-			&user.Deleted,
-		)
+		user, err := scanUser(rows)
 		if err != nil {
 			return nil, err
 		}
-		users = append(users, user)
+		users = append(users, *user)
 	}
 
 	return users, rows.Err()
 }
 
 func (r *UserRepository) GetUserByID(ctx context.Context, id int64) (*entity.User, error) {
-	query := `
-		SELECT id, full_name, email, password, created_at, updated_at, last_login, deleted
+	query := fmt.Sprintf(`
+		SELECT %s
 		FROM users
 		WHERE id = $1 AND deleted = false
-	`
+	`, userColumns)
 
-	user := &entity.User{}
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&user.ID,
-		&user.FullName,
-		&user.Email,
-		&user.Password,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-		&user.LastLogin,
-		&user.Deleted,
-	)
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
 
+	return user, nil
+}
+
+func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (*entity.User, error) {
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM users
+		WHERE email = $1 AND deleted = false
+	`, userColumns)
+
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, email))
 	if err == sql.ErrNoRows {
 		return nil, ErrUserNotFound
 	}
@@ -87,8 +132,8 @@ func (r *UserRepository) GetUserByID(ctx context.Context, id int64) (*entity.Use
 
 func (r *UserRepository) CreateUser(ctx context.Context, user *entity.User) error {
 	query := `
-		INSERT INTO users (full_name, email, password, created_at, updated_at, deleted)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO users (full_name, email, password, created_at, updated_at, deleted, role, email_verified_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		RETURNING id
 	`
 
@@ -99,25 +144,99 @@ func (r *UserRepository) CreateUser(ctx context.Context, user *entity.User) erro
 		user.CreatedAt,
 		user.UpdatedAt,
 		user.Deleted,
+		user.Role,
+		user.EmailVerifiedAt,
 	).Scan(&user.ID)
 
 	return err
 }
 
+// CreateFirstAdmin inserts user as an administrator only while no active administrator exists.
+// An advisory lock serializes concurrent bootstrap attempts. It is only reachable from the command
+// line, never over HTTP.
+func (r *UserRepository) CreateFirstAdmin(ctx context.Context, user *entity.User) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('users.bootstrap_admin'))`); err != nil {
+		return err
+	}
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM users WHERE role = $1 AND deleted = false)
+	`, entity.RoleAdmin).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrAdminExists
+	}
+
+	user.Role = entity.RoleAdmin
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO users (full_name, email, password, created_at, updated_at, deleted, role, email_verified_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`,
+		user.FullName,
+		user.Email,
+		user.Password,
+		user.CreatedAt,
+		user.UpdatedAt,
+		user.Deleted,
+		user.Role,
+		user.EmailVerifiedAt,
+	).Scan(&user.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateUser stores the user. A new PasswordChangedAt bumps the token version in the same statement,
+// so concurrent password changes each revoke the tokens issued before them.
 func (r *UserRepository) UpdateUser(ctx context.Context, user *entity.User) error {
 	query := `
 		UPDATE users
-		SET full_name = $1, email = $2, password = $3, updated_at = $4
-		WHERE id = $5 AND deleted = false
+		SET full_name = $1, email = $2, password = $3, updated_at = $4, email_verified_at = $5,
+			token_version = CASE
+				WHEN password_changed_at IS DISTINCT FROM $6 THEN token_version + 1
+				ELSE token_version
+			END,
+			password_changed_at = $6
+		WHERE id = $7 AND deleted = false
+		RETURNING token_version
 	`
 
-	result, err := r.db.ExecContext(ctx, query,
+	err := r.db.QueryRowContext(ctx, query,
 		user.FullName,
 		user.Email,
 		user.Password,
 		user.UpdatedAt,
+		user.EmailVerifiedAt,
+		user.PasswordChangedAt,
 		user.ID,
-	)
+	).Scan(&user.TokenVersion)
+	if err == sql.ErrNoRows {
+		return ErrUserNotFound
+	}
+
+	return err
+}
+
+func (r *UserRepository) DeleteUser(ctx context.Context, id int64) error {
+	query := `
+		UPDATE users
+		SET deleted = true, updated_at = NOW()
+		WHERE id = $1 AND deleted = false
+	`
+
+	result, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
 		return err
 	}
@@ -133,14 +252,14 @@ func (r *UserRepository) UpdateUser(ctx context.Context, user *entity.User) erro
 	return nil
 }
 
-func (r *UserRepository) DeleteUser(ctx context.Context, id int64) error {
+func (r *UserRepository) UpdateRole(ctx context.Context, id int64, role entity.Role) error {
 	query := `
 		UPDATE users
-		SET deleted = true, updated_at = NOW()
-		WHERE id = $1 AND deleted = false
+		SET role = $1, updated_at = NOW()
+		WHERE id = $2 AND deleted = false
 	`
 
-	result, err := r.db.ExecContext(ctx, query, id)
+	result, err := r.db.ExecContext(ctx, query, role, id)
 	if err != nil {
 		return err
 	}
@@ -156,6 +275,34 @@ func (r *UserRepository) DeleteUser(ctx context.Context, id int64) error {
 	return nil
 }
 
+// HardDeleteUser permanently removes the user row, detaching any files they own first
+func (r *UserRepository) HardDeleteUser(ctx context.Context, id int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE files SET owner_id = NULL WHERE owner_id = $1`, id); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrUserNotFound
+	}
+
+	return tx.Commit()
+}
+
 func (r *UserRepository) UpdateLastLogin(ctx context.Context, id int64) error {
 	query := `
 		UPDATE users
@@ -178,3 +325,122 @@ func (r *UserRepository) UpdateLastLogin(ctx context.Context, id int64) error {
 
 	return nil
 }
+
+// unlocked limits an update to accounts that are neither locked by an administrator nor inside a lockout
+const unlocked = "is_locked = false AND (locked_until IS NULL OR locked_until <= NOW())"
+
+// CountLoginAttempt counts a login attempt before its password is compared, so concurrent attempts
+// cannot get past maxAttempts. It reports false when the account is locked, including when this
+// attempt is past maxAttempts and starts the lockout itself.
+func (r *UserRepository) CountLoginAttempt(ctx context.Context, id int64, maxAttempts int, lockout time.Duration) (bool, error) {
+	query := `
+		UPDATE users
+		SET locked_until = CASE
+				WHEN failed_login_attempts >= $1 THEN NOW() + $2 * INTERVAL '1 second'
+				ELSE locked_until
+			END,
+			failed_login_attempts = CASE
+				WHEN failed_login_attempts >= $1 THEN 0
+				ELSE failed_login_attempts + 1
+			END
+		WHERE id = $3 AND deleted = false AND ` + unlocked + `
+		RETURNING failed_login_attempts > 0
+	`
+
+	var allowed bool
+	err := r.db.QueryRowContext(ctx, query, maxAttempts, lockout.Seconds(), id).Scan(&allowed)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return allowed, nil
+}
+
+// RecordLogin stores a successful login and clears the counted attempts. It returns ErrUserLocked
+// when a concurrent attempt locked the account after this one was counted.
+func (r *UserRepository) RecordLogin(ctx context.Context, id int64, ip string) error {
+	query := `
+		UPDATE users
+		SET last_login = NOW(), updated_at = NOW(), login_count = login_count + 1,
+			last_login_ip = $1, failed_login_attempts = 0, locked_until = NULL
+		WHERE id = $2 AND deleted = false AND ` + unlocked
+
+	return r.execUnlocked(ctx, query, ip, id)
+}
+
+// ClearFailedLogins forgets the counted attempts after a correct password that did not end in a login
+func (r *UserRepository) ClearFailedLogins(ctx context.Context, id int64) error {
+	query := `
+		UPDATE users
+		SET failed_login_attempts = 0
+		WHERE id = $1 AND deleted = false AND ` + unlocked
+
+	return r.execUnlocked(ctx, query, id)
+}
+
+// RecordFailedLogin locks the account for lockout once the attempt counted by CountLoginAttempt
+// turns out to be the maxAttempts-th failure in a row. It reports whether this call started the lockout.
+func (r *UserRepository) RecordFailedLogin(ctx context.Context, id int64, maxAttempts int, lockout time.Duration) (bool, error) {
+	query := `
+		UPDATE users
+		SET locked_until = NOW() + $2 * INTERVAL '1 second', failed_login_attempts = 0
+		WHERE id = $3 AND deleted = false AND failed_login_attempts >= $1 AND ` + unlocked
+
+	result, err := r.db.ExecContext(ctx, query, maxAttempts, lockout.Seconds(), id)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+func (r *UserRepository) execUnlocked(ctx context.Context, query string, args ...any) error {
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrUserLocked
+	}
+
+	return nil
+}
+
+// SetLocked locks or unlocks the account; unlocking also clears failed attempts and temporary lockouts
+func (r *UserRepository) SetLocked(ctx context.Context, id int64, locked bool) error {
+	query := `
+		UPDATE users
+		SET is_locked = $1, updated_at = NOW(),
+			failed_login_attempts = CASE WHEN $1 THEN failed_login_attempts ELSE 0 END,
+			locked_until = CASE WHEN $1 THEN locked_until ELSE NULL END
+		WHERE id = $2 AND deleted = false
+	`
+
+	result, err := r.db.ExecContext(ctx, query, locked, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
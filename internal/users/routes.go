@@ -2,17 +2,21 @@ package users
 
 import (
 	"net/http"
+	"strings"
+
+	"github.com/yansilvacerqueira/api-files/internal/users/entity"
 )
 
 func (h *Handler) SetRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/users", h.handleUsers)
+	mux.HandleFunc("/api/users/me", h.auth.RequireAuth(h.handleMe))
 	mux.HandleFunc("/api/users/", h.handleUserByID)
 }
 
 func (h *Handler) handleUsers(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
-		h.getUsers(w, r)
+		h.auth.RequirePermission(entity.PermissionManageUsers, h.getUsers)(w, r)
 	case http.MethodPost:
 		h.createUser(w, r)
 	default:
@@ -20,14 +24,57 @@ func (h *Handler) handleUsers(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (h *Handler) handleMe(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.handleUserProfile(w, r)
+	case http.MethodPut:
+		h.updateUserProfile(w, r)
+	case http.MethodDelete:
+		h.deleteUserProfile(w, r)
+	default:
+		h.respondWithError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
 func (h *Handler) handleUserByID(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/role"):
+		h.handleUserRole(w, r)
+		return
+	case strings.HasSuffix(r.URL.Path, "/lock"):
+		h.handleUserLock(w, r, h.lockUser)
+		return
+	case strings.HasSuffix(r.URL.Path, "/unlock"):
+		h.handleUserLock(w, r, h.unlockUser)
+		return
+	}
+
 	switch r.Method {
 	case http.MethodGet:
-		h.getUsers(w, r)
+		h.auth.RequireAuth(h.getUserByID)(w, r)
 	case http.MethodPut:
-		h.updateUser(w, r)
+		h.auth.RequireAuth(h.updateUser)(w, r)
 	case http.MethodDelete:
-		h.deleteUser(w, r)
+		h.auth.RequireAuth(h.deleteUser)(w, r)
+	default:
+		h.respondWithError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (h *Handler) handleUserRole(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPut:
+		h.auth.RequirePermission(entity.PermissionManageUsers, h.updateUserRole)(w, r)
+	default:
+		h.respondWithError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (h *Handler) handleUserLock(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	switch r.Method {
+	case http.MethodPost:
+		h.auth.RequirePermission(entity.PermissionManageUsers, next)(w, r)
 	default:
 		h.respondWithError(w, http.StatusMethodNotAllowed, "method not allowed")
 	}
@@ -0,0 +1,40 @@
+package worker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/yansilvacerqueira/api-files/internal/queue"
+)
+
+// checksum streams the raw object through SHA-256 and records the digest on the file
+func (h *handlers) checksum(ctx context.Context, message queue.QueueMessage, payload queue.FilePayload) error {
+	object, err := h.raw.Open(payload.Key())
+	if err != nil {
+		return permanentIfGone(fmt.Errorf("error downloading file: %w", err))
+	}
+	defer object.Body.Close()
+
+	// The bucket API takes no context, so closing the body is what stops the read
+	stop := context.AfterFunc(ctx, func() {
+		object.Body.Close()
+	})
+	defer stop()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, object.Body); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return fmt.Errorf("error reading file: %w", err)
+	}
+
+	if err := h.files.SetChecksum(ctx, int64(payload.ID), hex.EncodeToString(hash.Sum(nil))); err != nil {
+		return permanentIfGone(fmt.Errorf("error recording checksum: %w", err))
+	}
+
+	return nil
+}
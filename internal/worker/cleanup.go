@@ -0,0 +1,38 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/yansilvacerqueira/api-files/internal/files/entity"
+	"github.com/yansilvacerqueira/api-files/internal/files/repository"
+	"github.com/yansilvacerqueira/api-files/internal/queue"
+)
+
+// cleanup removes the raw object, the compressed copy and the thumbnail of a deleted file.
+// Removing objects that do not exist succeeds, so the job can safely run again.
+func (h *handlers) cleanup(ctx context.Context, message queue.QueueMessage, payload queue.FilePayload) error {
+	// Refuse to touch files that are still live
+	_, err := h.files.GetFileByID(ctx, int64(payload.ID))
+	if err == nil {
+		return queue.Permanent(fmt.Errorf("file %d is not deleted", payload.ID))
+	}
+	if !errors.Is(err, repository.ErrFileNotFound) {
+		return fmt.Errorf("error fetching file: %w", err)
+	}
+
+	key := payload.Key()
+
+	if err := h.raw.Delete(key); err != nil {
+		return fmt.Errorf("error removing raw object: %w", err)
+	}
+	if err := h.compact.Delete(key); err != nil {
+		return fmt.Errorf("error removing compressed object: %w", err)
+	}
+	if err := h.compact.Delete(entity.ThumbnailKey(key)); err != nil {
+		return fmt.Errorf("error removing thumbnail: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/yansilvacerqueira/api-files/internal/bucket"
+	"github.com/yansilvacerqueira/api-files/internal/codec"
+	"github.com/yansilvacerqueira/api-files/internal/queue"
+)
+
+// compress writes the compressed copy of a file to the compact bucket and records its codec
+func (h *handlers) compress(ctx context.Context, message queue.QueueMessage, payload queue.FilePayload) error {
+	fileCodec := h.defaultCodec
+	if payload.Codec != "" {
+		var err error
+		if fileCodec, err = codec.Get(payload.Codec); err != nil {
+			return queue.Permanent(err)
+		}
+	}
+
+	level := h.defaultLevel
+	if payload.Level != nil {
+		level = *payload.Level
+	}
+	if err := codec.ValidateLevel(fileCodec, level); err != nil {
+		return queue.Permanent(err)
+	}
+
+	if err := compressFile(ctx, h.raw, payload.Key(), fileCodec, level); err != nil {
+		return permanentIfGone(err)
+	}
+
+	if err := h.files.SetCodec(ctx, int64(payload.ID), fileCodec.Name()); err != nil {
+		return permanentIfGone(fmt.Errorf("error recording codec: %w", err))
+	}
+
+	return nil
+}
+
+// compressFile streams the raw object through fileCodec into the compact bucket under the same key.
+// Download, compression and upload run concurrently through a pipe, so memory use is bounded by
+// the uploader part size instead of the file size. Cancelling ctx aborts the transfer.
+func compressFile(ctx context.Context, fileBucket ObjectStore, key string, fileCodec codec.Codec, level int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	object, err := fileBucket.Open(key)
+	if err != nil {
+		return fmt.Errorf("error downloading file: %w", err)
+	}
+	defer object.Body.Close()
+
+	pipeReader, pipeWriter := io.Pipe()
+
+	// The bucket API takes no context, so breaking the pipe is what stops both sides
+	stop := context.AfterFunc(ctx, func() {
+		pipeReader.CloseWithError(ctx.Err())
+		object.Body.Close()
+	})
+	defer stop()
+	compressErr := make(chan error, 1)
+
+	go func() {
+		encoder, err := fileCodec.NewWriter(pipeWriter, level)
+		if err == nil {
+			_, err = io.Copy(encoder, object.Body)
+			if closeErr := encoder.Close(); err == nil {
+				err = closeErr
+			}
+		}
+
+		pipeWriter.CloseWithError(err)
+		compressErr <- err
+	}()
+
+	err = fileBucket.UploadWithOptions(pipeReader, key, bucket.UploadOptions{
+		ContentType:     object.ContentType,
+		ContentEncoding: fileCodec.ContentEncoding(),
+	})
+	if err != nil {
+		// Unblocks the compressor when the upload stopped reading early
+		pipeReader.CloseWithError(err)
+		<-compressErr
+		return fmt.Errorf("error uploading compressed file: %w", err)
+	}
+
+	if err := <-compressErr; err != nil {
+		return fmt.Errorf("error compressing file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,196 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/yansilvacerqueira/api-files/internal/bucket"
+	"github.com/yansilvacerqueira/api-files/internal/codec"
+	"github.com/yansilvacerqueira/api-files/internal/files/repository"
+	"github.com/yansilvacerqueira/api-files/internal/queue"
+)
+
+const (
+	defaultThumbnailSize        = 256
+	defaultThumbnailConcurrency = 1
+)
+
+// handlers implements the file jobs: compression, thumbnails, checksums and cleanup
+type handlers struct {
+	raw           ObjectStore
+	compact       ObjectStore
+	files         FileStore
+	jobs          JobStore
+	logger        *log.Logger
+	defaultCodec  codec.Codec
+	defaultLevel  int
+	thumbnailSize int
+	// thumbnailSlots holds a token for every thumbnail being rendered
+	thumbnailSlots chan struct{}
+}
+
+func newHandlers(cfg Config, logger *log.Logger) (*handlers, error) {
+	if cfg.Raw == nil {
+		return nil, errors.New("raw bucket is required")
+	}
+	if cfg.Compact == nil {
+		return nil, errors.New("compact bucket is required")
+	}
+	if cfg.Files == nil {
+		return nil, errors.New("file repository is required")
+	}
+	if cfg.Jobs == nil {
+		return nil, errors.New("job repository is required")
+	}
+
+	codecName := cfg.DefaultCodec
+	if codecName == "" {
+		codecName = "gzip"
+	}
+	defaultCodec, err := codec.Get(codecName)
+	if err != nil {
+		return nil, err
+	}
+
+	defaultLevel := codec.DefaultLevel
+	if cfg.DefaultLevel != nil {
+		defaultLevel = *cfg.DefaultLevel
+	}
+	if err := codec.ValidateLevel(defaultCodec, defaultLevel); err != nil {
+		return nil, err
+	}
+
+	thumbnailSize := cfg.ThumbnailSize
+	if thumbnailSize <= 0 {
+		thumbnailSize = defaultThumbnailSize
+	}
+
+	thumbnailConcurrency := cfg.ThumbnailConcurrency
+	if thumbnailConcurrency <= 0 {
+		thumbnailConcurrency = defaultThumbnailConcurrency
+	}
+
+	return &handlers{
+		raw:            cfg.Raw,
+		compact:        cfg.Compact,
+		files:          cfg.Files,
+		jobs:           cfg.Jobs,
+		logger:         logger,
+		defaultCodec:   defaultCodec,
+		defaultLevel:   defaultLevel,
+		thumbnailSize:  thumbnailSize,
+		thumbnailSlots: make(chan struct{}, thumbnailConcurrency),
+	}, nil
+}
+
+// register adds every file job to registry along with its timeout and retry policy
+func (h *handlers) register(registry *Registry) error {
+	defaults := []Handler{
+		{
+			Type:    queue.MessageTypeCompress,
+			Handle:  h.fileJob(h.compress),
+			Timeout: 30 * time.Minute,
+			Retry:   queue.RetryPolicy{MaxRetries: 5, Delay: 5 * time.Second},
+		},
+		{
+			// Decoding is all in memory and deterministic, so retrying rarely helps
+			Type:    queue.MessageTypeThumbnail,
+			Handle:  h.fileJob(h.thumbnail),
+			Timeout: 2 * time.Minute,
+			Retry:   queue.RetryPolicy{MaxRetries: 2, Delay: 10 * time.Second},
+		},
+		{
+			Type:    queue.MessageTypeChecksum,
+			Handle:  h.fileJob(h.checksum),
+			Timeout: 30 * time.Minute,
+			Retry:   queue.RetryPolicy{MaxRetries: 5, Delay: 5 * time.Second},
+		},
+		{
+			// Leftover objects only cost storage, so cleanup keeps trying for longer
+			Type:    queue.MessageTypeCleanup,
+			Handle:  h.fileJob(h.cleanup),
+			Timeout: time.Minute,
+			Retry:   queue.RetryPolicy{MaxRetries: 10, Delay: 30 * time.Second},
+		},
+	}
+
+	for _, handler := range defaults {
+		if err := registry.Register(handler); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fileJob decodes the FilePayload of a message and runs job under job tracking.
+// Malformed payloads are failed permanently.
+func (h *handlers) fileJob(job func(ctx context.Context, message queue.QueueMessage, payload queue.FilePayload) error) queue.MessageHandler {
+	return func(ctx context.Context, message queue.QueueMessage) error {
+		var payload queue.FilePayload
+		if err := message.DecodePayload(&payload); err != nil {
+			h.logger.Printf("Error decoding message %s: %v", message.CorrelationID, err)
+			return queue.Permanent(err)
+		}
+
+		err := h.trackJob(ctx, payload.JobID, func() error {
+			return job(ctx, message, payload)
+		})
+		if err != nil {
+			h.logger.Printf("Error running %s job for file %d [%s]: %v", message.Type, payload.ID, message.CorrelationID, err)
+		}
+		return err
+	}
+}
+
+// trackJob runs job and records its attempt on the processing_jobs row jobID; untracked
+// messages have no jobID. Bookkeeping failures are logged only, so they never turn a
+// processed file into a retry.
+func (h *handlers) trackJob(ctx context.Context, jobID int64, job func() error) error {
+	if jobID == 0 {
+		return job()
+	}
+
+	// Updates must land even when the job itself ran out of time
+	recordJob := func(update func(context.Context) error) {
+		recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+
+		if err := update(recordCtx); err != nil {
+			h.logger.Printf("Error updating job %d: %v", jobID, err)
+		}
+	}
+
+	recordJob(func(ctx context.Context) error {
+		return h.jobs.MarkRunning(ctx, jobID)
+	})
+
+	err := job()
+
+	attempt, _ := queue.AttemptFromContext(ctx)
+	switch {
+	case err == nil:
+		recordJob(func(ctx context.Context) error {
+			return h.jobs.MarkSucceeded(ctx, jobID)
+		})
+	case queue.IsPermanent(err) || attempt.Last():
+		recordJob(func(ctx context.Context) error {
+			return h.jobs.MarkFailed(ctx, jobID, err.Error())
+		})
+	default:
+		recordJob(func(ctx context.Context) error {
+			return h.jobs.MarkRetrying(ctx, jobID, err.Error())
+		})
+	}
+
+	return err
+}
+
+// permanentIfGone fails a job permanently when the file or its object no longer exists
+func permanentIfGone(err error) error {
+	if errors.Is(err, bucket.ErrObjectNotFound) || errors.Is(err, repository.ErrFileNotFound) {
+		return queue.Permanent(err)
+	}
+	return err
+}
@@ -0,0 +1,181 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/yansilvacerqueira/api-files/internal/bucket"
+	"github.com/yansilvacerqueira/api-files/internal/files/entity"
+	"github.com/yansilvacerqueira/api-files/internal/queue"
+)
+
+const (
+	defaultDrainTimeout = 30 * time.Second
+	// abortGracePeriod is how long Run waits for aborted jobs to be settled after the drain timeout
+	abortGracePeriod = 5 * time.Second
+)
+
+// ErrDrainTimeout is returned by Run when in-flight messages did not finish in time
+var ErrDrainTimeout = errors.New("drain timeout exceeded")
+
+// ObjectStore is the part of bucket.Bucket the jobs use
+type ObjectStore interface {
+	Open(key string) (*bucket.Object, error)
+	Stat(key string) (*bucket.ObjectInfo, error)
+	UploadWithOptions(file io.Reader, key string, opts bucket.UploadOptions) error
+	Delete(key string) error
+}
+
+// FileStore is the part of the files repository the jobs use
+type FileStore interface {
+	GetFileByID(ctx context.Context, id int64) (*entity.File, error)
+	SetCodec(ctx context.Context, id int64, codec string) error
+	SetChecksum(ctx context.Context, id int64, checksum string) error
+}
+
+// JobStore records the progress of tracked messages, as the jobs repository does
+type JobStore interface {
+	MarkRunning(ctx context.Context, id int64) error
+	MarkSucceeded(ctx context.Context, id int64) error
+	MarkRetrying(ctx context.Context, id int64, reason string) error
+	MarkFailed(ctx context.Context, id int64, reason string) error
+}
+
+// Consumer delivers queue messages, as queue.Queue does
+type Consumer interface {
+	ReceiveMessage(ctx context.Context, handler queue.MessageHandler) error
+	Close() error
+}
+
+// Processor runs the file jobs for the messages of a queue
+type Processor struct {
+	registry     *Registry
+	logger       *log.Logger
+	drainTimeout time.Duration
+}
+
+type Config struct {
+	// Raw reads raw uploads and writes into the compact bucket
+	Raw ObjectStore
+	// Compact points at the compact bucket on both sides; cleanup deletes through it
+	Compact ObjectStore
+	Files   FileStore
+	Jobs    JobStore
+	Logger  *log.Logger
+	// DefaultCodec compresses files whose message does not choose a codec, defaults to gzip
+	DefaultCodec string
+	// DefaultLevel is the compression level of messages that do not choose one; nil uses the codec default
+	DefaultLevel *int
+	// ThumbnailSize is the longest side of generated thumbnails in pixels, defaults to 256
+	ThumbnailSize int
+	// ThumbnailConcurrency is how many thumbnails are rendered at once, defaults to 1; each decodes
+	// its whole image in memory
+	ThumbnailConcurrency int
+	// DrainTimeout is how long Run waits for in-flight messages after ctx is cancelled, defaults to 30s
+	DrainTimeout time.Duration
+}
+
+// NewProcessor creates a Processor with the compress, thumbnail, checksum and cleanup jobs registered
+func NewProcessor(cfg Config) (*Processor, error) {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	handlers, err := newHandlers(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	registry := NewRegistry()
+	if err := handlers.register(registry); err != nil {
+		return nil, err
+	}
+
+	drainTimeout := cfg.DrainTimeout
+	if drainTimeout <= 0 {
+		drainTimeout = defaultDrainTimeout
+	}
+
+	return &Processor{
+		registry:     registry,
+		logger:       logger,
+		drainTimeout: drainTimeout,
+	}, nil
+}
+
+// Register adds a handler for another message type, for commands embedding the processor
+func (p *Processor) Register(handler Handler) error {
+	return p.registry.Register(handler)
+}
+
+// RetryPolicies returns the retry policy of every job type, for queue.RabbitMQConfig
+func (p *Processor) RetryPolicies() map[string]queue.RetryPolicy {
+	return p.registry.RetryPolicies()
+}
+
+// Types lists the message types the processor handles
+func (p *Processor) Types() []string {
+	return p.registry.Types()
+}
+
+// Handle processes one message; it is the queue.MessageHandler Run consumes with
+func (p *Processor) Handle(ctx context.Context, message queue.QueueMessage) error {
+	return p.registry.Dispatch(ctx, message)
+}
+
+// Run consumes messages until ctx is cancelled, then waits up to the drain timeout for
+// in-flight messages before closing the consumer. Jobs still running at that point are cancelled,
+// so their uploads abort before the process exits, and ErrDrainTimeout is returned; their
+// messages are retried, or redelivered if they could not be settled in time.
+func (p *Processor) Run(ctx context.Context, consumer Consumer) error {
+	// Consumers detach handlers from ctx so they can finish while draining; jobsCtx aborts them
+	jobsCtx, cancelJobs := context.WithCancel(context.Background())
+	defer cancelJobs()
+
+	handle := func(msgCtx context.Context, message queue.QueueMessage) error {
+		msgCtx, cancel := context.WithCancel(msgCtx)
+		defer cancel()
+
+		stop := context.AfterFunc(jobsCtx, cancel)
+		defer stop()
+
+		return p.Handle(msgCtx, message)
+	}
+
+	consumeErr := make(chan error, 1)
+	go func() {
+		consumeErr <- consumer.ReceiveMessage(ctx, handle)
+	}()
+
+	var err error
+	select {
+	case err = <-consumeErr:
+
+	case <-ctx.Done():
+		p.logger.Printf("Shutting down, waiting up to %s for in-flight messages", p.drainTimeout)
+
+		select {
+		case err = <-consumeErr:
+		case <-time.After(p.drainTimeout):
+			p.logger.Printf("Drain timeout exceeded, cancelling in-flight jobs")
+			cancelJobs()
+
+			select {
+			case <-consumeErr:
+			case <-time.After(abortGracePeriod):
+			}
+			err = ErrDrainTimeout
+		}
+	}
+
+	if closeErr := consumer.Close(); closeErr != nil && err == nil {
+		err = fmt.Errorf("failed to close queue: %w", closeErr)
+	}
+
+	return err
+}
@@ -0,0 +1,461 @@
+package worker
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yansilvacerqueira/api-files/internal/bucket"
+	"github.com/yansilvacerqueira/api-files/internal/codec"
+	"github.com/yansilvacerqueira/api-files/internal/files/entity"
+	"github.com/yansilvacerqueira/api-files/internal/files/repository"
+	"github.com/yansilvacerqueira/api-files/internal/queue"
+)
+
+const testKey = "uploads/x/a.txt"
+
+// fakeFiles is an in-memory FileStore; failChecksum makes that many SetChecksum calls fail
+type fakeFiles struct {
+	mu           sync.Mutex
+	files        map[int64]*entity.File
+	failChecksum int
+}
+
+func newFakeFiles(ids ...int64) *fakeFiles {
+	files := &fakeFiles{files: make(map[int64]*entity.File)}
+	for _, id := range ids {
+		files.files[id] = &entity.File{ID: id, Path: testKey}
+	}
+	return files
+}
+
+func (f *fakeFiles) GetFileByID(ctx context.Context, id int64) (*entity.File, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, ok := f.files[id]
+	if !ok {
+		return nil, repository.ErrFileNotFound
+	}
+	copied := *file
+	return &copied, nil
+}
+
+func (f *fakeFiles) SetCodec(ctx context.Context, id int64, codec string) error {
+	return f.update(id, func(file *entity.File) { file.Codec = codec })
+}
+
+func (f *fakeFiles) SetChecksum(ctx context.Context, id int64, checksum string) error {
+	f.mu.Lock()
+	if f.failChecksum > 0 {
+		f.failChecksum--
+		f.mu.Unlock()
+		return errors.New("database unavailable")
+	}
+	f.mu.Unlock()
+
+	return f.update(id, func(file *entity.File) { file.Checksum = checksum })
+}
+
+func (f *fakeFiles) update(id int64, update func(*entity.File)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, ok := f.files[id]
+	if !ok {
+		return repository.ErrFileNotFound
+	}
+	update(file)
+	return nil
+}
+
+// fakeJobs is an in-memory JobStore recording every status change of every job
+type fakeJobs struct {
+	mu     sync.Mutex
+	events map[int64][]string
+}
+
+func newFakeJobs() *fakeJobs {
+	return &fakeJobs{events: make(map[int64][]string)}
+}
+
+func (j *fakeJobs) MarkRunning(ctx context.Context, id int64) error { return j.record(id, "running") }
+func (j *fakeJobs) MarkSucceeded(ctx context.Context, id int64) error {
+	return j.record(id, "succeeded")
+}
+func (j *fakeJobs) MarkRetrying(ctx context.Context, id int64, reason string) error {
+	return j.record(id, "retrying")
+}
+func (j *fakeJobs) MarkFailed(ctx context.Context, id int64, reason string) error {
+	return j.record(id, "failed")
+}
+
+func (j *fakeJobs) record(id int64, event string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.events[id] = append(j.events[id], event)
+	return nil
+}
+
+func (j *fakeJobs) Events(id int64) []string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return append([]string(nil), j.events[id]...)
+}
+
+// testEnv wires a Processor to in-memory buckets, fakes and a MemoryQueue. Like the worker, the
+// raw side reads the raw bucket and writes into the compact one.
+type testEnv struct {
+	raw       *bucket.MemoryStorage
+	compact   *bucket.MemoryStorage
+	files     *fakeFiles
+	jobs      *fakeJobs
+	queue     *queue.MemoryQueue
+	processor *Processor
+}
+
+func newTestEnv(t *testing.T, files *fakeFiles, cfg Config) *testEnv {
+	t.Helper()
+
+	env := &testEnv{
+		raw:     bucket.NewMemoryStorage("raw", "compact"),
+		compact: bucket.NewMemoryStorage("compact", "compact"),
+		files:   files,
+		jobs:    newFakeJobs(),
+		queue:   queue.NewMemoryQueue(),
+	}
+
+	cfg.Raw = bucket.NewBucketWithProvider(env.raw)
+	cfg.Compact = bucket.NewBucketWithProvider(env.compact)
+	cfg.Files = env.files
+	cfg.Jobs = env.jobs
+	cfg.Logger = log.New(io.Discard, "", 0)
+
+	processor, err := NewProcessor(cfg)
+	if err != nil {
+		t.Fatalf("NewProcessor: %v", err)
+	}
+	env.processor = processor
+	env.queue.RetryPolicies = processor.RetryPolicies()
+
+	return env
+}
+
+func (env *testEnv) publish(t *testing.T, messageType string, payload any) {
+	t.Helper()
+
+	message, err := queue.NewQueueMessage(messageType, payload)
+	if err != nil {
+		t.Fatalf("NewQueueMessage: %v", err)
+	}
+	body, err := message.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON: %v", err)
+	}
+	if err := env.queue.PublishMessage(body); err != nil {
+		t.Fatalf("PublishMessage: %v", err)
+	}
+}
+
+func (env *testEnv) publishFile(t *testing.T, messageType string, jobID int64) {
+	t.Helper()
+	env.publish(t, messageType, queue.FilePayload{Filename: "a.txt", Path: "uploads/x", ID: 1, JobID: jobID})
+}
+
+// run processes every published message; closing the queue first makes Run return once it is drained
+func (env *testEnv) run(t *testing.T) {
+	t.Helper()
+
+	env.queue.Close()
+	if err := env.processor.Run(context.Background(), env.queue); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+}
+
+func assertEvents(t *testing.T, jobs *fakeJobs, id int64, want ...string) {
+	t.Helper()
+
+	if got := jobs.Events(id); !reflect.DeepEqual(got, want) {
+		t.Errorf("job %d: got events %v, want %v", id, got, want)
+	}
+}
+
+func TestProcessorCompressesFiles(t *testing.T) {
+	content := bytes.Repeat([]byte("compressible "), 100)
+
+	env := newTestEnv(t, newFakeFiles(1), Config{})
+	env.raw.Put("raw", testKey, content)
+	env.publishFile(t, queue.MessageTypeCompress, 7)
+	env.run(t)
+
+	compressed, ok := env.raw.Object("compact", testKey)
+	if !ok {
+		t.Fatal("no compressed copy in the compact bucket")
+	}
+	if opts, _ := env.raw.Metadata("compact", testKey); opts.ContentEncoding != "gzip" {
+		t.Errorf("got content encoding %q, want gzip", opts.ContentEncoding)
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %v", err)
+	}
+	decompressed, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("decompressing: %v", err)
+	}
+	if !bytes.Equal(decompressed, content) {
+		t.Error("decompressed copy differs from the upload")
+	}
+
+	file, _ := env.files.GetFileByID(context.Background(), 1)
+	if file.Codec != "gzip" {
+		t.Errorf("got codec %q, want gzip", file.Codec)
+	}
+	assertEvents(t, env.jobs, 7, "running", "succeeded")
+	if deadLetters := env.queue.DeadLetters(); len(deadLetters) != 0 {
+		t.Errorf("got %d dead letters", len(deadLetters))
+	}
+}
+
+func TestProcessorHonorsExplicitLevelZero(t *testing.T) {
+	content := bytes.Repeat([]byte("compressible "), 100)
+
+	env := newTestEnv(t, newFakeFiles(1), Config{})
+	env.raw.Put("raw", testKey, content)
+
+	level := 0
+	env.publish(t, queue.MessageTypeCompress, queue.FilePayload{Filename: "a.txt", Path: "uploads/x", ID: 1, Level: &level})
+	env.run(t)
+
+	// Level 0 stores the data, so the copy is larger than the input rather than compressed
+	compressed, _ := env.raw.Object("compact", testKey)
+	if len(compressed) <= len(content) {
+		t.Errorf("got %d bytes from %d, want the uncompressed size of level 0", len(compressed), len(content))
+	}
+}
+
+func TestProcessorComputesChecksums(t *testing.T) {
+	content := []byte("hello world")
+
+	env := newTestEnv(t, newFakeFiles(1), Config{})
+	env.raw.Put("raw", testKey, content)
+	env.publishFile(t, queue.MessageTypeChecksum, 3)
+	env.run(t)
+
+	sum := sha256.Sum256(content)
+	file, _ := env.files.GetFileByID(context.Background(), 1)
+	if file.Checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("got checksum %q", file.Checksum)
+	}
+	assertEvents(t, env.jobs, 3, "running", "succeeded")
+}
+
+func TestProcessorCleansUpDeletedFiles(t *testing.T) {
+	env := newTestEnv(t, newFakeFiles(), Config{})
+	env.raw.Put("raw", testKey, []byte("raw"))
+	env.compact.Put("compact", testKey, []byte("compressed"))
+	env.compact.Put("compact", entity.ThumbnailKey(testKey), []byte("thumbnail"))
+	env.publishFile(t, queue.MessageTypeCleanup, 0)
+	env.run(t)
+
+	if keys := env.raw.Keys("raw"); len(keys) != 0 {
+		t.Errorf("raw objects left behind: %v", keys)
+	}
+	if keys := env.compact.Keys("compact"); len(keys) != 0 {
+		t.Errorf("compact objects left behind: %v", keys)
+	}
+}
+
+func TestProcessorRefusesToCleanUpLiveFiles(t *testing.T) {
+	env := newTestEnv(t, newFakeFiles(1), Config{})
+	env.raw.Put("raw", testKey, []byte("raw"))
+	env.publishFile(t, queue.MessageTypeCleanup, 5)
+	env.run(t)
+
+	if keys := env.raw.Keys("raw"); len(keys) != 1 {
+		t.Errorf("cleanup removed the object of a live file")
+	}
+	if deadLetters := env.queue.DeadLetters(); len(deadLetters) != 1 {
+		t.Errorf("got %d dead letters, want 1", len(deadLetters))
+	}
+	assertEvents(t, env.jobs, 5, "running", "failed")
+}
+
+func TestProcessorRetriesTransientFailures(t *testing.T) {
+	files := newFakeFiles(1)
+	files.failChecksum = 2
+
+	env := newTestEnv(t, files, Config{})
+	env.raw.Put("raw", testKey, []byte("hello world"))
+	env.publishFile(t, queue.MessageTypeChecksum, 3)
+	env.run(t)
+
+	assertEvents(t, env.jobs, 3, "running", "retrying", "running", "retrying", "running", "succeeded")
+	if deadLetters := env.queue.DeadLetters(); len(deadLetters) != 0 {
+		t.Errorf("got %d dead letters", len(deadLetters))
+	}
+}
+
+func TestProcessorDeadLettersExhaustedRetries(t *testing.T) {
+	files := newFakeFiles(1)
+	files.failChecksum = 100
+
+	env := newTestEnv(t, files, Config{})
+	env.raw.Put("raw", testKey, []byte("hello world"))
+	env.publishFile(t, queue.MessageTypeChecksum, 3)
+	env.run(t)
+
+	// The checksum policy allows 5 retries after the first attempt
+	events := env.jobs.Events(3)
+	if len(events) != 12 || events[len(events)-1] != "failed" {
+		t.Errorf("got events %v, want 6 attempts ending in failed", events)
+	}
+	if deadLetters := env.queue.DeadLetters(); len(deadLetters) != 1 {
+		t.Errorf("got %d dead letters, want 1", len(deadLetters))
+	}
+}
+
+func TestProcessorDeadLettersMissingObjects(t *testing.T) {
+	env := newTestEnv(t, newFakeFiles(1), Config{})
+	env.publishFile(t, queue.MessageTypeCompress, 7)
+	env.run(t)
+
+	assertEvents(t, env.jobs, 7, "running", "failed")
+	if deadLetters := env.queue.DeadLetters(); len(deadLetters) != 1 {
+		t.Errorf("got %d dead letters, want 1", len(deadLetters))
+	}
+}
+
+func TestProcessorDeadLettersLevelsOutsideTheDefaultCodecRange(t *testing.T) {
+	env := newTestEnv(t, newFakeFiles(1), Config{})
+	env.raw.Put("raw", testKey, []byte("hello world"))
+
+	// Without a codec the payload cannot be checked until the worker picks its default, gzip
+	level := 42
+	env.publish(t, queue.MessageTypeCompress, queue.FilePayload{Filename: "a.txt", Path: "uploads/x", ID: 1, JobID: 7, Level: &level})
+	env.run(t)
+
+	assertEvents(t, env.jobs, 7, "running", "failed")
+	if deadLetters := env.queue.DeadLetters(); len(deadLetters) != 1 {
+		t.Errorf("got %d dead letters, want 1", len(deadLetters))
+	}
+}
+
+func TestNewProcessorRejectsInvalidDefaultLevel(t *testing.T) {
+	level := 10
+	_, err := NewProcessor(Config{
+		Raw:          bucket.NewBucketWithProvider(bucket.NewMemoryStorage("raw", "compact")),
+		Compact:      bucket.NewBucketWithProvider(bucket.NewMemoryStorage("compact", "compact")),
+		Files:        newFakeFiles(),
+		Jobs:         newFakeJobs(),
+		DefaultLevel: &level,
+	})
+	if !errors.Is(err, codec.ErrInvalidLevel) {
+		t.Errorf("got error %v, want codec.ErrInvalidLevel", err)
+	}
+}
+
+func TestProcessorDeadLettersUnknownTypes(t *testing.T) {
+	env := newTestEnv(t, newFakeFiles(1), Config{})
+	env.publishFile(t, "transcode", 0)
+	env.run(t)
+
+	if deadLetters := env.queue.DeadLetters(); len(deadLetters) != 1 {
+		t.Errorf("got %d dead letters, want 1", len(deadLetters))
+	}
+}
+
+func TestProcessorRunCancelsJobsAfterDrainTimeout(t *testing.T) {
+	env := newTestEnv(t, newFakeFiles(1), Config{DrainTimeout: 50 * time.Millisecond})
+
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	err := env.processor.Register(Handler{
+		Type: "block",
+		Handle: func(ctx context.Context, message queue.QueueMessage) error {
+			close(started)
+			<-ctx.Done()
+			close(cancelled)
+			return ctx.Err()
+		},
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	env.publishFile(t, "block", 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- env.processor.Run(ctx, env.queue)
+	}()
+
+	<-started
+	cancel()
+
+	select {
+	case err := <-runErr:
+		if !errors.Is(err, ErrDrainTimeout) {
+			t.Errorf("got %v, want ErrDrainTimeout", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the drain timeout")
+	}
+
+	select {
+	case <-cancelled:
+	default:
+		t.Error("the in-flight job was not cancelled")
+	}
+	if env.queue.State() != queue.StateClosed {
+		t.Error("Run did not close the consumer")
+	}
+}
+
+func TestProcessorRunWaitsForInFlightJobs(t *testing.T) {
+	env := newTestEnv(t, newFakeFiles(1), Config{DrainTimeout: 5 * time.Second})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	err := env.processor.Register(Handler{
+		Type: "slow",
+		Handle: func(ctx context.Context, message queue.QueueMessage) error {
+			close(started)
+			<-release
+			return ctx.Err()
+		},
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	env.publishFile(t, "slow", 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- env.processor.Run(ctx, env.queue)
+	}()
+
+	<-started
+	cancel()
+	close(release)
+
+	if err := <-runErr; err != nil {
+		t.Errorf("got %v, want the job to finish within the drain timeout", err)
+	}
+	if deadLetters := env.queue.DeadLetters(); len(deadLetters) != 0 {
+		t.Errorf("the drained job failed: %d dead letters", len(deadLetters))
+	}
+}
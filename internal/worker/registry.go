@@ -0,0 +1,99 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yansilvacerqueira/api-files/internal/queue"
+)
+
+// Handler processes the messages of one type
+type Handler struct {
+	// Type is the queue message type the handler is dispatched for
+	Type   string
+	Handle queue.MessageHandler
+	// Timeout bounds a single attempt; zero leaves only the queue-wide job timeout
+	Timeout time.Duration
+	// Retry overrides the queue-wide retry settings for this type
+	Retry queue.RetryPolicy
+}
+
+// Registry dispatches queue messages to the handler registered for their type
+type Registry struct {
+	mu       sync.RWMutex
+	handlers map[string]Handler
+}
+
+func NewRegistry() *Registry {
+	return &Registry{handlers: make(map[string]Handler)}
+}
+
+// Register adds a handler; every message type can only be registered once
+func (r *Registry) Register(handler Handler) error {
+	if handler.Type == "" {
+		return errors.New("handler type is required")
+	}
+	if handler.Handle == nil {
+		return fmt.Errorf("handler %q has no handle function", handler.Type)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.handlers[handler.Type]; exists {
+		return fmt.Errorf("handler %q is already registered", handler.Type)
+	}
+
+	r.handlers[handler.Type] = handler
+	return nil
+}
+
+// Dispatch is a queue.MessageHandler running the handler of the message type within its timeout.
+// Messages of unknown types can never succeed and are failed permanently.
+func (r *Registry) Dispatch(ctx context.Context, message queue.QueueMessage) error {
+	r.mu.RLock()
+	handler, ok := r.handlers[message.Type]
+	r.mu.RUnlock()
+
+	if !ok {
+		return queue.Permanent(fmt.Errorf("no handler registered for message type %q", message.Type))
+	}
+
+	if handler.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, handler.Timeout)
+		defer cancel()
+	}
+
+	return handler.Handle(ctx, message)
+}
+
+// RetryPolicies returns the retry policy of every registered type, for queue.RabbitMQConfig
+func (r *Registry) RetryPolicies() map[string]queue.RetryPolicy {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	policies := make(map[string]queue.RetryPolicy, len(r.handlers))
+	for messageType, handler := range r.handlers {
+		policies[messageType] = handler.Retry
+	}
+	return policies
+}
+
+// Types lists the registered message types in lexical order
+func (r *Registry) Types() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	types := make([]string, 0, len(r.handlers))
+	for messageType := range r.handlers {
+		types = append(types, messageType)
+	}
+	sort.Strings(types)
+
+	return types
+}
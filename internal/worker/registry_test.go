@@ -0,0 +1,105 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/yansilvacerqueira/api-files/internal/queue"
+)
+
+func noop(context.Context, queue.QueueMessage) error { return nil }
+
+func TestRegistryRegister(t *testing.T) {
+	registry := NewRegistry()
+
+	if err := registry.Register(Handler{Type: "a", Handle: noop}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if err := registry.Register(Handler{Type: "a", Handle: noop}); err == nil {
+		t.Error("registering a type twice succeeded")
+	}
+	if err := registry.Register(Handler{Handle: noop}); err == nil {
+		t.Error("registering a handler without a type succeeded")
+	}
+	if err := registry.Register(Handler{Type: "b"}); err == nil {
+		t.Error("registering a handler without a handle function succeeded")
+	}
+}
+
+func TestRegistryDispatch(t *testing.T) {
+	registry := NewRegistry()
+
+	var got []string
+	for _, messageType := range []string{"a", "b"} {
+		err := registry.Register(Handler{
+			Type: messageType,
+			Handle: func(ctx context.Context, message queue.QueueMessage) error {
+				got = append(got, message.Type)
+				return nil
+			},
+		})
+		if err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+	}
+
+	for _, messageType := range []string{"b", "a"} {
+		if err := registry.Dispatch(context.Background(), queue.QueueMessage{Type: messageType}); err != nil {
+			t.Fatalf("Dispatch %s: %v", messageType, err)
+		}
+	}
+	if !reflect.DeepEqual(got, []string{"b", "a"}) {
+		t.Errorf("got dispatches %v", got)
+	}
+
+	err := registry.Dispatch(context.Background(), queue.QueueMessage{Type: "unknown"})
+	if err == nil || !queue.IsPermanent(err) {
+		t.Errorf("got %v for an unknown type, want a permanent error", err)
+	}
+}
+
+func TestRegistryDispatchAppliesTimeout(t *testing.T) {
+	registry := NewRegistry()
+	err := registry.Register(Handler{
+		Type:    "slow",
+		Timeout: 10 * time.Millisecond,
+		Handle: func(ctx context.Context, message queue.QueueMessage) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	err = registry.Dispatch(context.Background(), queue.QueueMessage{Type: "slow"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want the handler timeout to expire", err)
+	}
+}
+
+func TestRegistryPoliciesAndTypes(t *testing.T) {
+	registry := NewRegistry()
+	policy := queue.RetryPolicy{MaxRetries: 2, Delay: time.Second}
+
+	for _, handler := range []Handler{
+		{Type: "thumbnail", Handle: noop, Retry: policy},
+		{Type: "compress", Handle: noop},
+	} {
+		if err := registry.Register(handler); err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+	}
+
+	if got := registry.Types(); !reflect.DeepEqual(got, []string{"compress", "thumbnail"}) {
+		t.Errorf("got types %v", got)
+	}
+
+	want := map[string]queue.RetryPolicy{"thumbnail": policy, "compress": {}}
+	if got := registry.RetryPolicies(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got policies %v, want %v", got, want)
+	}
+}
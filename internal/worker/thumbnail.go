@@ -0,0 +1,136 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // GIF decoder
+	"image/jpeg"
+	_ "image/png" // PNG decoder
+	"io"
+
+	"github.com/yansilvacerqueira/api-files/internal/bucket"
+	"github.com/yansilvacerqueira/api-files/internal/files/entity"
+	"github.com/yansilvacerqueira/api-files/internal/queue"
+)
+
+// Images are decoded in memory, so both the encoded and the decoded size are bounded. A 16-bit
+// image takes 8 bytes per pixel, so one thumbnail can hold up to about 450 MiB; thumbnailSlots
+// limits how many are rendered at once.
+const (
+	maxThumbnailSourceSize = 50 << 20 // 50 MiB
+	maxThumbnailPixels     = 50_000_000
+)
+
+var errImageTooLarge = errors.New("image is too large for a thumbnail")
+
+// thumbnail renders a JPEG preview of an image upload, scaled so its longest side is at most
+// the configured size. Files that are not JPEG, PNG or GIF images fail permanently. Rendering
+// waits for a free thumbnail slot, so memory use does not grow with the worker concurrency.
+func (h *handlers) thumbnail(ctx context.Context, message queue.QueueMessage, payload queue.FilePayload) error {
+	key := payload.Key()
+
+	info, err := h.raw.Stat(key)
+	if err != nil {
+		return permanentIfGone(fmt.Errorf("error describing file: %w", err))
+	}
+	if info.Size > maxThumbnailSourceSize {
+		return queue.Permanent(errImageTooLarge)
+	}
+
+	select {
+	case h.thumbnailSlots <- struct{}{}:
+		defer func() { <-h.thumbnailSlots }()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	object, err := h.raw.Open(key)
+	if err != nil {
+		return permanentIfGone(fmt.Errorf("error downloading file: %w", err))
+	}
+	data, err := io.ReadAll(io.LimitReader(object.Body, maxThumbnailSourceSize+1))
+	object.Body.Close()
+	if err != nil {
+		return fmt.Errorf("error reading file: %w", err)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return queue.Permanent(fmt.Errorf("unsupported image: %w", err))
+	}
+	if config.Width*config.Height > maxThumbnailPixels {
+		return queue.Permanent(errImageTooLarge)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return queue.Permanent(fmt.Errorf("error decoding image: %w", err))
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var thumbnail bytes.Buffer
+	if err := jpeg.Encode(&thumbnail, scaleDown(img, h.thumbnailSize), &jpeg.Options{Quality: 80}); err != nil {
+		return fmt.Errorf("error encoding thumbnail: %w", err)
+	}
+
+	err = h.raw.UploadWithOptions(&thumbnail, entity.ThumbnailKey(key), bucket.UploadOptions{ContentType: "image/jpeg"})
+	if err != nil {
+		return fmt.Errorf("error uploading thumbnail: %w", err)
+	}
+
+	return nil
+}
+
+// scaleDown shrinks src so its longest side is size, averaging every source pixel that falls
+// into a thumbnail pixel. Transparent areas are flattened onto white since JPEG has no alpha.
+func scaleDown(src image.Image, size int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	dstWidth, dstHeight := width, height
+	if width > size || height > size {
+		if width >= height {
+			dstWidth, dstHeight = size, max(1, height*size/width)
+		} else {
+			dstWidth, dstHeight = max(1, width*size/height), size
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	for y := 0; y < dstHeight; y++ {
+		y0 := bounds.Min.Y + y*height/dstHeight
+		y1 := bounds.Min.Y + (y+1)*height/dstHeight
+
+		for x := 0; x < dstWidth; x++ {
+			x0 := bounds.Min.X + x*width/dstWidth
+			x1 := bounds.Min.X + (x+1)*width/dstWidth
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+
+			// Colors are alpha-premultiplied, so adding the missing coverage blends onto white
+			background := 0xffff - a/n
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r/n + background),
+				G: uint16(g/n + background),
+				B: uint16(b/n + background),
+				A: 0xffff,
+			})
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,111 @@
+package worker
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/yansilvacerqueira/api-files/internal/files/entity"
+	"github.com/yansilvacerqueira/api-files/internal/queue"
+)
+
+func filledImage(width, height int, c color.Color) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestScaleDownSize(t *testing.T) {
+	tests := []struct {
+		name                  string
+		width, height, size   int
+		wantWidth, wantHeight int
+	}{
+		{name: "landscape", width: 400, height: 200, size: 100, wantWidth: 100, wantHeight: 50},
+		{name: "portrait", width: 200, height: 400, size: 100, wantWidth: 50, wantHeight: 100},
+		{name: "square", width: 300, height: 300, size: 100, wantWidth: 100, wantHeight: 100},
+		{name: "thin strip", width: 1000, height: 1, size: 100, wantWidth: 100, wantHeight: 1},
+		{name: "already small", width: 40, height: 20, size: 100, wantWidth: 40, wantHeight: 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bounds := scaleDown(filledImage(tt.width, tt.height, color.Black), tt.size).Bounds()
+			if bounds.Dx() != tt.wantWidth || bounds.Dy() != tt.wantHeight {
+				t.Errorf("got %dx%d, want %dx%d", bounds.Dx(), bounds.Dy(), tt.wantWidth, tt.wantHeight)
+			}
+		})
+	}
+}
+
+func TestScaleDownColors(t *testing.T) {
+	// Left half black, right half white: every thumbnail pixel keeps the color of its half
+	src := filledImage(4, 2, color.White)
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 2; x++ {
+			src.Set(x, y, color.Black)
+		}
+	}
+
+	dst := scaleDown(src, 2)
+	if r, _, _, _ := dst.At(0, 0).RGBA(); r != 0 {
+		t.Errorf("got red %d on the black half", r)
+	}
+	if r, _, _, _ := dst.At(1, 0).RGBA(); r != 0xffff {
+		t.Errorf("got red %d on the white half", r)
+	}
+
+	// Transparent pixels are flattened onto white
+	transparent := scaleDown(filledImage(2, 2, color.Transparent), 1)
+	if r, g, b, a := transparent.At(0, 0).RGBA(); r != 0xffff || g != 0xffff || b != 0xffff || a != 0xffff {
+		t.Errorf("got %d %d %d %d for a transparent image, want opaque white", r, g, b, a)
+	}
+}
+
+func TestProcessorRendersThumbnails(t *testing.T) {
+	var source bytes.Buffer
+	if err := png.Encode(&source, filledImage(600, 300, color.RGBA{R: 200, A: 0xff})); err != nil {
+		t.Fatalf("png.Encode: %v", err)
+	}
+
+	env := newTestEnv(t, newFakeFiles(1), Config{ThumbnailSize: 60})
+	env.raw.Put("raw", testKey, source.Bytes())
+	env.publishFile(t, queue.MessageTypeThumbnail, 4)
+	env.run(t)
+
+	data, ok := env.raw.Object("compact", entity.ThumbnailKey(testKey))
+	if !ok {
+		t.Fatal("no thumbnail in the compact bucket")
+	}
+	if opts, _ := env.raw.Metadata("compact", entity.ThumbnailKey(testKey)); opts.ContentType != "image/jpeg" {
+		t.Errorf("got content type %q", opts.ContentType)
+	}
+
+	thumbnail, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("jpeg.Decode: %v", err)
+	}
+	if bounds := thumbnail.Bounds(); bounds.Dx() != 60 || bounds.Dy() != 30 {
+		t.Errorf("got a %dx%d thumbnail, want 60x30", bounds.Dx(), bounds.Dy())
+	}
+	assertEvents(t, env.jobs, 4, "running", "succeeded")
+}
+
+func TestProcessorFailsThumbnailsOfNonImages(t *testing.T) {
+	env := newTestEnv(t, newFakeFiles(1), Config{})
+	env.raw.Put("raw", testKey, []byte("plain text"))
+	env.publishFile(t, queue.MessageTypeThumbnail, 4)
+	env.run(t)
+
+	assertEvents(t, env.jobs, 4, "running", "failed")
+	if deadLetters := env.queue.DeadLetters(); len(deadLetters) != 1 {
+		t.Errorf("got %d dead letters, want 1", len(deadLetters))
+	}
+}
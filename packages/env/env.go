@@ -0,0 +1,47 @@
+package env
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// These helpers read the configuration of the commands. Malformed values are configuration
+// mistakes, so they exit instead of falling back to the default.
+
+// String retrieves an environment variable or returns a default value
+func String(key, defaultValue string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return value
+	}
+	return defaultValue
+}
+
+// Int reads an integer environment variable, exiting on malformed values
+func Int(key string, defaultValue int) int {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return defaultValue
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", key, err)
+	}
+	return value
+}
+
+// Duration reads a duration environment variable such as "30s", exiting on malformed values
+func Duration(key string, defaultValue time.Duration) time.Duration {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return defaultValue
+	}
+
+	value, err := time.ParseDuration(raw)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", key, err)
+	}
+	return value
+}
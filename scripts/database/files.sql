@@ -1,4 +1,4 @@
-CREATE TABLE files (
+CREATE TABLE IF NOT EXISTS files (
   id SERIAL,
   owner_id INT,
   folder_id INT,
@@ -8,7 +8,15 @@ CREATE TABLE files (
   created_at TIMESTAMP DEFAULT current_timestamp,
   updated_at TIMESTAMP NOT NULL,
   deleted BOOL NOT NULL DEFAULT false,
+  codec VARCHAR(20),
+  checksum VARCHAR(64),
   PRIMARY KEY(id),
   CONSTRAINT fk_users FOREIGN KEY(owner_id) REFERENCES users(id),
   CONSTRAINT fk_folders FOREIGN KEY(folder_id) REFERENCES folders(id)
-)
\ No newline at end of file
+);
+
+-- Rows from before compression codecs existed have no codec and are treated as not compressed
+ALTER TABLE files ADD COLUMN IF NOT EXISTS codec VARCHAR(20);
+
+-- Checksums are filled in by the checksum job of each new upload
+ALTER TABLE files ADD COLUMN IF NOT EXISTS checksum VARCHAR(64);
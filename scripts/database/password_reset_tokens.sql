@@ -0,0 +1,10 @@
+CREATE TABLE password_reset_tokens (
+  id SERIAL,
+  user_id INT NOT NULL,
+  token_hash VARCHAR(64) NOT NULL UNIQUE,
+  expires_at TIMESTAMP NOT NULL,
+  used_at TIMESTAMP,
+  created_at TIMESTAMP DEFAULT current_timestamp,
+  PRIMARY KEY(id),
+  CONSTRAINT fk_users FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+)
\ No newline at end of file
@@ -0,0 +1,17 @@
+CREATE TABLE processing_jobs (
+  id SERIAL,
+  file_id INT NOT NULL,
+  type VARCHAR(30) NOT NULL,
+  status VARCHAR(20) NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'succeeded', 'failed')),
+  attempts INT NOT NULL DEFAULT 0,
+  error TEXT,
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  updated_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  started_at TIMESTAMP,
+  finished_at TIMESTAMP,
+  PRIMARY KEY(id),
+  CONSTRAINT fk_files FOREIGN KEY(file_id) REFERENCES files(id) ON DELETE CASCADE
+);
+
+-- The status endpoint lists jobs by file, and deleting a files row cascades through file_id
+CREATE INDEX IF NOT EXISTS idx_processing_jobs_file_id ON processing_jobs(file_id);
@@ -1,4 +1,4 @@
-CREATE TABLE users (
+CREATE TABLE IF NOT EXISTS users (
   id SERIAL,
   full_name VARCHAR(60) NOT NULL,
   email VARCHAR(60) NOT NULL UNIQUE,
@@ -7,5 +7,53 @@ CREATE TABLE users (
   updated_at TIMESTAMP NOT NULL,
   last_login TIMESTAMP DEFAULT current_timestamp,
   deleted BOOL NOT NULL DEFAULT false,
+  role VARCHAR(20) NOT NULL DEFAULT 'member' CHECK (role IN ('admin', 'member', 'read_only')),
+  is_locked BOOL NOT NULL DEFAULT false,
+  locked_until TIMESTAMP,
+  failed_login_attempts INT NOT NULL DEFAULT 0,
+  login_count INT NOT NULL DEFAULT 0,
+  last_login_ip VARCHAR(45),
+  email_verified_at TIMESTAMP,
+  password_changed_at TIMESTAMPTZ,
+  token_version INT NOT NULL DEFAULT 0,
   PRIMARY KEY(id)
-)
\ No newline at end of file
+);
+
+-- Accounts created before email verification existed are treated as verified. The backfill only
+-- runs while the column is being added, so accounts still waiting for verification are left alone.
+DO $$
+BEGIN
+  IF NOT EXISTS (
+    SELECT 1 FROM information_schema.columns
+    WHERE table_name = 'users' AND column_name = 'email_verified_at'
+  ) THEN
+    ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP;
+    UPDATE users SET email_verified_at = COALESCE(created_at, NOW());
+  END IF;
+END $$;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_changed_at TIMESTAMPTZ;
+
+-- Bearer tokens carry the token version they were signed with and are rejected once a password
+-- change bumps it. Tokens signed before the column existed carry none, so accounts that already
+-- changed their password start at 1 and have to sign in again.
+DO $$
+BEGIN
+  IF NOT EXISTS (
+    SELECT 1 FROM information_schema.columns
+    WHERE table_name = 'users' AND column_name = 'token_version'
+  ) THEN
+    ALTER TABLE users ADD COLUMN token_version INT NOT NULL DEFAULT 0;
+    UPDATE users SET token_version = 1 WHERE password_changed_at IS NOT NULL;
+  END IF;
+END $$;
+
+-- Roles were added after the first release; existing accounts become members
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'member' CHECK (role IN ('admin', 'member', 'read_only'));
+
+-- Lockout and login tracking columns for tables created before they existed
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_locked BOOL NOT NULL DEFAULT false;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS failed_login_attempts INT NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS login_count INT NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_login_ip VARCHAR(45);